@@ -1,182 +1,573 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/Inphi/eip4844-interop/shared/p2pclient"
+	"github.com/Inphi/eip4844-interop/shared/sidecar"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/libp2p/go-libp2p/core/peer"
 
-	ma "github.com/multiformats/go-multiaddr"
-
-	"github.com/libp2p/go-libp2p"
-	libp2pcore "github.com/libp2p/go-libp2p-core"
-	"github.com/libp2p/go-libp2p-core/host"
-	"github.com/libp2p/go-libp2p-core/peer"
-	"github.com/libp2p/go-libp2p-core/protocol"
-
-	"github.com/prysmaticlabs/prysm/v3/beacon-chain/p2p"
-	"github.com/prysmaticlabs/prysm/v3/beacon-chain/p2p/encoder"
-	"github.com/prysmaticlabs/prysm/v3/beacon-chain/sync"
-	types "github.com/prysmaticlabs/prysm/v3/consensus-types/primitives"
+	"github.com/prysmaticlabs/prysm/v3/api/client/beacon"
 	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
 )
 
+// Exit codes
+const (
+	exitError     = 1
+	exitNoBlobs   = 2
+	exitMalformed = 3
+)
+
+const discoveryTimeout = 30 * time.Second
+
+type config struct {
+	start        uint64
+	count        uint64
+	addr         string
+	enr          string
+	bootENR      string
+	manifestPath string
+	out          string
+	split        string
+	outDir       string
+
+	source          string
+	roots           string
+	tx              string
+	versionedHashes string
+	ethAddr         string
+	beaconAPI       string
+	searchBlocks    uint64
+	noVerify        bool
+}
+
+type downloadReport struct {
+	Source   string          `json:"source"`
+	Sidecars []sidecarReport `json:"sidecars"`
+	// Locations are set when blobs are downloaded by transaction or versioned hash
+	Locations []location `json:"locations,omitempty"`
+	// Written is the number of payload bytes written
+	Written int64  `json:"written"`
+	Error   string `json:"error,omitempty"`
+}
+
+type location struct {
+	TxHash          common.Hash   `json:"txHash"`
+	BlockNumber     uint64        `json:"blockNumber"`
+	BlockHash       common.Hash   `json:"blockHash"`
+	Slot            uint64        `json:"slot"`
+	VersionedHashes []common.Hash `json:"versionedHashes"`
+}
+
+type sidecarReport struct {
+	Slot            uint64        `json:"slot"`
+	BeaconBlockRoot hexutil.Bytes `json:"beaconBlockRoot"`
+	Blobs           int           `json:"blobs"`
+	// BlobLengths holds the decoded payload length of every blob, or -1 for malformed blobs
+	BlobLengths []int64 `json:"blobLengths"`
+	Malformed   []int   `json:"malformed,omitempty"`
+	// Invalid explains why the sidecar doesn't match its beacon block
+	Invalid string `json:"invalid,omitempty"`
+}
+
 func main() {
-	start := flag.Uint64("start", 0, "Start slot to download blobs from")
-	count := flag.Uint64("count", 1, "Number of slots to download blobs from (default: 1)")
-	addr := flag.String("addr", "", "P2P address to connect to")
+	var cfg config
+	flag.Uint64Var(&cfg.start, "start", 0, "Start slot to download blobs from")
+	flag.Uint64Var(&cfg.count, "count", 1, "Number of slots to download blobs from (default: 1)")
+	flag.StringVar(&cfg.addr, "addr", "", "P2P address to connect to, including the /p2p/<peer id> suffix")
+	flag.StringVar(&cfg.enr, "enr", "", "ENR of the beacon node to connect to, instead of --addr")
+	flag.StringVar(&cfg.bootENR, "boot-enr", shared.BootENRFilepath(), "Bootnode ENR list used to discover a beacon node through discv5 when neither --addr nor --enr is set")
+	flag.StringVar(&cfg.manifestPath, "manifest", "", "Reassemble the payload described by this upload manifest from the sidecars in the requested slots")
+	flag.StringVar(&cfg.out, "out", "", "Write the payload to this file instead of stdout")
+	flag.StringVar(&cfg.split, "split", "", "Write the payload of every slot (slot) or blob (blob) to its own file in --out-dir instead of concatenating them")
+	flag.StringVar(&cfg.outDir, "out-dir", ".", "Directory the files written with --split go to")
+	flag.StringVar(&cfg.source, "source", "p2p", "Where to retrieve sidecars from: p2p, through the RPC of the peer at --addr, or rest, through the HTTP API at --beacon-api")
+	flag.StringVar(&cfg.roots, "roots", "", "Comma-separated beacon block roots to download blobs from, through the by-root RPC, instead of a slot range")
+	flag.StringVar(&cfg.tx, "tx", "", "Download the blobs of this transaction, found through its receipt, instead of a slot range")
+	flag.StringVar(&cfg.versionedHashes, "versioned-hash", "", "Comma-separated versioned hashes of the blobs to download. Searched in the recent blocks unless --tx or --start is set")
+	flag.StringVar(&cfg.ethAddr, "eth-addr", shared.GethRPC, "JSON-RPC endpoint used to find transactions")
+	flag.StringVar(&cfg.beaconAPI, "beacon-api", "http://"+shared.BeaconAPI, "Beacon node REST API used to read the chain config, find the slot of a transaction, and download sidecars with --source rest")
+	flag.Uint64Var(&cfg.searchBlocks, "search-blocks", 64, "Number of recent blocks searched for --versioned-hash")
+	flag.BoolVar(&cfg.noVerify, "no-verify", false, "Don't check the sidecars against the commitments of their beacon blocks, read from --beacon-api")
+	output := flag.String("output", "text", "Output format, text or json. With json, a report is printed to stdout and the payload is only written if --out is set")
 	flag.Parse()
 
-	if *start == 0 {
-		panic("start parameter must be greater than 0")
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *output)
+		os.Exit(exitError)
+	}
+
+	report := &downloadReport{Sidecars: []sidecarReport{}}
+	code, err := run(cfg, *output == "json", report)
+	if err != nil {
+		report.Error = err.Error()
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 	}
-	if *addr == "" {
-		panic("missing addr parameter")
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to write report: %v\n", err)
+			os.Exit(exitError)
+		}
 	}
+	os.Exit(code)
+}
 
+// run downloads the sidecars described by cfg and writes their payload.
+// It returns the exit code of the command, along with the error that caused it if any.
+func run(cfg config, jsonOutput bool, report *downloadReport) (int, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	req := &ethpb.BlobsSidecarsByRangeRequest{
-		StartSlot: types.Slot(*start),
-		Count:     *count,
+	wanted, err := parseHashes(cfg.versionedHashes, "versioned hash")
+	if err != nil {
+		return exitError, err
 	}
-
-	h, err := libp2p.New()
+	roots, err := parseHashes(cfg.roots, "block root")
 	if err != nil {
-		panic(err)
+		return exitError, err
+	}
+	if len(roots) > 0 && (cfg.start != 0 || cfg.tx != "") {
+		return exitError, errors.New("--roots can't be combined with --start or --tx")
+	}
+	// the slots of the located transactions, requested one by one instead of the --start range
+	var slots []uint64
+	if cfg.tx != "" || (len(wanted) > 0 && cfg.start == 0 && len(roots) == 0) {
+		locs, err := findBlobs(ctx, cfg, wanted)
+		if err != nil {
+			return exitNoBlobs, err
+		}
+		for _, loc := range locs {
+			log.Printf("blobs of tx %v are in block %d, slot %d", loc.TxHash, loc.BlockNumber, loc.Slot)
+			report.Locations = append(report.Locations, location{
+				TxHash:          loc.TxHash,
+				BlockNumber:     loc.BlockNumber,
+				BlockHash:       loc.BlockHash,
+				Slot:            loc.Slot,
+				VersionedHashes: loc.VersionedHashes,
+			})
+			if !containsSlot(slots, loc.Slot) {
+				slots = append(slots, loc.Slot)
+			}
+		}
+		sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+		cfg.start = slots[0]
+		if len(wanted) == 0 {
+			wanted = locs[0].VersionedHashes
+		}
 	}
-	defer func() {
-		_ = h.Close()
-	}()
 
-	multiaddr, err := getMultiaddr(ctx, h, *addr)
-	if err != nil {
-		panic(err)
+	if cfg.start == 0 && len(roots) == 0 {
+		return exitError, errors.New("start parameter must be greater than 0")
+	}
+	if cfg.split != "" && cfg.split != "slot" && cfg.split != "blob" {
+		return exitError, fmt.Errorf("unknown split mode %q", cfg.split)
+	}
+	if cfg.addr != "" && cfg.enr != "" {
+		return exitError, errors.New("--addr and --enr are mutually exclusive")
 	}
 
-	addrInfo, err := peer.AddrInfoFromP2pAddr(multiaddr)
-	if err != nil {
-		panic(err)
+	var w io.Writer = io.Discard
+	if cfg.out != "" {
+		f, err := os.Create(cfg.out)
+		if err != nil {
+			return exitError, err
+		}
+		defer f.Close()
+		w = f
+	} else if !jsonOutput {
+		w = os.Stdout
 	}
+	counter := &countingWriter{w: w}
 
-	err = h.Connect(ctx, *addrInfo)
+	src, err := newSource(ctx, cfg)
 	if err != nil {
-		panic(err)
+		return exitError, err
+	}
+	if c, ok := src.(io.Closer); ok {
+		defer c.Close()
 	}
+	report.Source = src.Name()
 
-	// Hack to ensure that we are able to download blob chunks with larger chunk sizes (which is 10 MiB post-bellatrix)
-	encoder.MaxChunkSize = 10 << 20
-	sidecars, err := sendBlobsSidecarsByRangeRequest(ctx, h, encoder.SszNetworkEncoder{}, addrInfo.ID, req)
+	var sidecars []*ethpb.BlobsSidecar
+	if len(roots) > 0 {
+		sidecars, err = src.SidecarsByRoot(ctx, roots)
+	} else if len(slots) > 0 {
+		for _, slot := range slots {
+			var scs []*ethpb.BlobsSidecar
+			if scs, err = src.SidecarsByRange(ctx, slot, 1); err != nil {
+				break
+			}
+			sidecars = append(sidecars, scs...)
+		}
+	} else {
+		sidecars, err = src.SidecarsByRange(ctx, cfg.start, cfg.count)
+	}
 	if err != nil {
-		panic(err)
+		return exitError, fmt.Errorf("%s request failed: %w", src.Name(), err)
 	}
 
-	anyBlobs := false
 	for _, sidecar := range sidecars {
-		if sidecar.Blobs == nil || len(sidecar.Blobs) == 0 {
+		report.Sidecars = append(report.Sidecars, newSidecarReport(sidecar))
+	}
+	if !cfg.noVerify {
+		if err := verify(ctx, cfg, sidecars, len(roots) > 0, report); errors.Is(err, errInvalidSidecars) {
+			return exitMalformed, err
+		} else if err != nil {
+			return exitError, fmt.Errorf("unable to verify sidecars: %w", err)
+		}
+	}
+
+	if cfg.manifestPath != "" {
+		err := reassemble(cfg.manifestPath, sidecars, counter)
+		report.Written = counter.n
+		if err != nil {
+			return exitError, fmt.Errorf("unable to reassemble payload: %w", err)
+		}
+		return 0, nil
+	}
+
+	if len(wanted) > 0 {
+		var blobs [][]byte
+		for _, sidecar := range sidecars {
+			for _, blob := range sidecar.Blobs {
+				blobs = append(blobs, blob.Data)
+			}
+		}
+		matched, err := shared.FilterBlobs(blobs, wanted)
+		var missing *shared.MissingBlobsError
+		if err != nil && !errors.As(err, &missing) {
+			return exitError, err
+		}
+		for i, blob := range matched {
+			_, err := io.Copy(counter, shared.NewBlobReader(bytes.NewReader(blob)))
+			report.Written = counter.n
+			if err != nil {
+				return exitError, fmt.Errorf("unable to decode blob %d: %w", i, err)
+			}
+		}
+		if missing != nil {
+			return exitNoBlobs, missing
+		}
+		return 0, nil
+	}
+
+	anyBlobs := false
+	malformed := false
+	for _, sc := range sidecars {
+		if len(sc.Blobs) == 0 {
 			continue
 		}
 		anyBlobs = true
-		for _, blob := range sidecar.Blobs {
-			data := shared.DecodeFlatBlob(blob.Data)
-			_, _ = os.Stdout.Write(data)
+		s := sidecar.FromProto(sc)
+		var slotData []byte
+		for i := range s.Blobs {
+			data, err := s.BlobData(i)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "malformed %v\n", err)
+				malformed = true
+				continue
+			}
+			switch cfg.split {
+			case "slot":
+				slotData = append(slotData, data...)
+			case "blob":
+				err = writeFile(cfg.outDir, fmt.Sprintf("slot-%d-blob-%d.bin", s.Slot, i), data, counter)
+			default:
+				_, err = counter.Write(data)
+			}
+			if err != nil {
+				return exitError, err
+			}
 		}
-
-		// stop after the first sidecar with blobs:
-		break
+		if cfg.split == "slot" {
+			if err := writeFile(cfg.outDir, fmt.Sprintf("slot-%d.bin", s.Slot), slotData, counter); err != nil {
+				return exitError, err
+			}
+		}
+		report.Written = counter.n
 	}
 
 	if !anyBlobs {
-		panic(fmt.Sprintf("No blobs found in requested slots, sidecar count: %d", len(sidecars)))
+		return exitNoBlobs, fmt.Errorf("no blobs found in requested slots, sidecar count: %d", len(sidecars))
+	}
+	if malformed {
+		return exitMalformed, errors.New("some blobs are malformed")
 	}
+	return 0, nil
 }
 
-func getMultiaddr(ctx context.Context, h host.Host, addr string) (ma.Multiaddr, error) {
-	multiaddr, err := ma.NewMultiaddr(addr)
+var errInvalidSidecars = errors.New("sidecars don't match their beacon block")
+
+// verify checks the sidecars against their beacon blocks, recording mismatches in the report. Sidecars requested by
+// root are checked against the block with their root, the others against the canonical block at their slot.
+func verify(ctx context.Context, cfg config, sidecars []*ethpb.BlobsSidecar, byRoot bool, report *downloadReport) error {
+	client, err := beacon.NewClient(cfg.beaconAPI)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	_, id := peer.SplitAddr(multiaddr)
-	if id != "" {
-		return multiaddr, nil
+	verifySidecar := sidecar.VerifyCanonical
+	if byRoot {
+		verifySidecar = sidecar.VerifyByRoot
+	}
+	invalid := 0
+	for i, sc := range sidecars {
+		err := verifySidecar(ctx, client, sc)
+		var verr *sidecar.VerificationError
+		if errors.As(err, &verr) {
+			fmt.Fprintf(os.Stderr, "invalid %v\n", err)
+			report.Sidecars[i].Invalid = err.Error()
+			invalid++
+		} else if err != nil {
+			return err
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%w: %d of %d", errInvalidSidecars, invalid, len(sidecars))
+	}
+	return nil
+}
+
+// writeFile writes a payload to its own file in dir, counting its bytes in counter
+func writeFile(dir, name string, data []byte, counter *countingWriter) error {
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return err
+	}
+	counter.n += int64(len(data))
+	return nil
+}
+
+func newSource(ctx context.Context, cfg config) (sidecar.BlobSource, error) {
+	switch cfg.source {
+	case "p2p":
+		return newP2PSource(ctx, cfg)
+	case "rest":
+		client, err := beacon.NewClient(cfg.beaconAPI)
+		if err != nil {
+			return nil, err
+		}
+		return sidecar.NewRESTSource(client), nil
+	default:
+		return nil, fmt.Errorf("unknown source %q", cfg.source)
+	}
+}
+
+// p2pSource retrieves sidecars from a single beacon peer. The client must be closed after use.
+type p2pSource struct {
+	*p2pclient.PeerSource
+	client *p2pclient.Client
+}
+
+// newP2PSource connects to the peer given by --addr or --enr, or to the first beacon node discovered from the bootnodes.
+// The chain config needed for the Status handshake is read from --beacon-api. If it's unreachable,
+// the peer is queried without a handshake.
+func newP2PSource(ctx context.Context, cfg config) (*p2pSource, error) {
+	var chain *p2pclient.ChainConfig
+	beaconClient, err := beacon.NewClient(cfg.beaconAPI)
+	if err == nil {
+		chain, err = p2pclient.FetchChainConfig(ctx, beaconClient)
 	}
-	// peer ID wasn't provided, look it up
-	id, err = retrievePeerID(ctx, h, addr)
 	if err != nil {
+		log.Printf("Skipping the status handshake, unable to read the chain config from %s: %v", cfg.beaconAPI, err)
+	}
+	client, err := p2pclient.New(chain)
+	if err != nil {
+		return nil, err
+	}
+	pid, err := connect(ctx, client, cfg)
+	if err != nil {
+		_ = client.Close()
 		return nil, err
 	}
-	return ma.NewMultiaddr(fmt.Sprintf("%s/p2p/%s", addr, string(id)))
+	return &p2pSource{PeerSource: client.Source(pid), client: client}, nil
 }
 
-// Helper for retrieving the peer ID from a security error... obviously don't use this in production!
-// See https://github.com/libp2p/go-libp2p-noise/blob/v0.3.0/handshake.go#L250
-func retrievePeerID(ctx context.Context, h host.Host, addr string) (peer.ID, error) {
-	incorrectPeerID := "16Uiu2HAmSifdT5QutTsaET8xqjWAMPp4obrQv7LN79f2RMmBe3nY"
-	addrInfo, err := peer.AddrInfoFromString(fmt.Sprintf("%s/p2p/%s", addr, incorrectPeerID))
-	if err != nil {
-		return "", err
+func connect(ctx context.Context, client *p2pclient.Client, cfg config) (peer.ID, error) {
+	if cfg.addr != "" {
+		return client.Connect(ctx, cfg.addr)
 	}
-	err = h.Connect(ctx, *addrInfo)
-	if err == nil {
-		return "", errors.New("unexpected successful connection")
+	if cfg.enr != "" {
+		node, err := p2pclient.ParseENR(cfg.enr)
+		if err != nil {
+			return "", err
+		}
+		return client.ConnectNode(ctx, node)
 	}
-	if strings.Contains(err.Error(), "but remote key matches") {
-		split := strings.Split(err.Error(), " ")
-		return peer.ID(split[len(split)-1]), nil
+
+	bootnodes, err := p2pclient.ReadENRFile(cfg.bootENR)
+	if err != nil {
+		return "", fmt.Errorf("%w: no --addr or --enr given, and the bootnodes can't be read", err)
 	}
-	return "", err
+	discoverCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+	pids, err := client.ConnectDiscovered(discoverCtx, bootnodes, 1)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Connected to discovered peer %s", pids[0])
+	return pids[0], nil
 }
 
-func sendBlobsSidecarsByRangeRequest(ctx context.Context, h host.Host, encoding encoder.NetworkEncoding, pid peer.ID, req *ethpb.BlobsSidecarsByRangeRequest) ([]*ethpb.BlobsSidecar, error) {
-	topic := fmt.Sprintf("%s%s", p2p.RPCBlobsSidecarsByRangeTopicV1, encoding.ProtocolSuffix())
+func (s *p2pSource) Close() error {
+	return s.client.Close()
+}
 
-	stream, err := h.NewStream(ctx, pid, protocol.ID(topic))
+// findBlobs locates the transaction given by --tx, or the transactions carrying the wanted versioned hashes
+func findBlobs(ctx context.Context, cfg config, wanted []common.Hash) ([]*shared.BlobLocation, error) {
+	client, err := ethclient.DialContext(ctx, cfg.ethAddr)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = stream.Close()
-	}()
+	defer client.Close()
+	if cfg.tx != "" {
+		if !isHash(cfg.tx) {
+			return nil, fmt.Errorf("invalid tx hash %q", cfg.tx)
+		}
+		loc, err := shared.LocateTransactionBlobs(ctx, client, cfg.beaconAPI, common.HexToHash(cfg.tx))
+		if err != nil {
+			return nil, err
+		}
+		return []*shared.BlobLocation{loc}, nil
+	}
+	var locs []*shared.BlobLocation
+	located := make(map[common.Hash]bool)
+	for _, h := range wanted {
+		// the transaction located for an earlier hash may carry this one too
+		if located[h] {
+			continue
+		}
+		loc, err := shared.LocateVersionedHash(ctx, client, cfg.beaconAPI, h, cfg.searchBlocks)
+		if err != nil {
+			return nil, err
+		}
+		for _, vh := range loc.VersionedHashes {
+			located[vh] = true
+		}
+		locs = append(locs, loc)
+	}
+	return locs, nil
+}
 
-	if _, err := encoding.EncodeWithMaxLength(stream, req); err != nil {
-		_ = stream.Reset()
-		return nil, err
+func containsSlot(slots []uint64, slot uint64) bool {
+	for _, s := range slots {
+		if s == slot {
+			return true
+		}
 	}
+	return false
+}
 
-	if err := stream.CloseWrite(); err != nil {
-		_ = stream.Reset()
-		return nil, err
+func parseHashes(s, what string) ([]common.Hash, error) {
+	var hashes []common.Hash
+	for _, h := range strings.Split(s, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if !isHash(h) {
+			return nil, fmt.Errorf("invalid %s %q", what, h)
+		}
+		hashes = append(hashes, common.HexToHash(h))
 	}
+	return hashes, nil
+}
+
+func isHash(s string) bool {
+	b, err := hexutil.Decode(s)
+	return err == nil && len(b) == common.HashLength
+}
 
-	var blobsSidecars []*ethpb.BlobsSidecar
-	for {
-		blobs, err := readChunkedBlobsSidecar(stream, encoding)
-		if errors.Is(err, io.EOF) {
-			break
+func newSidecarReport(sidecar *ethpb.BlobsSidecar) sidecarReport {
+	r := sidecarReport{
+		Slot:            uint64(sidecar.BeaconBlockSlot),
+		BeaconBlockRoot: sidecar.BeaconBlockRoot,
+		Blobs:           len(sidecar.Blobs),
+		BlobLengths:     make([]int64, len(sidecar.Blobs)),
+	}
+	for i, blob := range sidecar.Blobs {
+		n := int64(-1)
+		if shared.ValidateBlob(blob.Data) == nil {
+			var err error
+			n, err = io.Copy(io.Discard, shared.NewBlobReader(bytes.NewReader(blob.Data)))
+			if err != nil {
+				n = -1
+			}
 		}
-		if err != nil {
-			return nil, err
+		if n < 0 {
+			r.Malformed = append(r.Malformed, i)
 		}
-		blobsSidecars = append(blobsSidecars, blobs)
+		r.BlobLengths[i] = n
 	}
-	return blobsSidecars, nil
+	return r
 }
 
-func readChunkedBlobsSidecar(stream libp2pcore.Stream, encoding encoder.NetworkEncoding) (*ethpb.BlobsSidecar, error) {
-	code, errMsg, err := sync.ReadStatusCode(stream, encoding)
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// reassemble writes the payload described by the manifest, using the blobs found in sidecars
+func reassemble(manifestPath string, sidecars []*ethpb.BlobsSidecar, w io.Writer) error {
+	manifest, err := shared.ReadManifest(manifestPath)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	wanted := make(map[common.Hash]bool)
+	for _, h := range manifest.Blobs {
+		wanted[h] = true
+	}
+
+	found := make(map[common.Hash][]byte)
+	for _, sidecar := range sidecars {
+		for _, blob := range sidecar.Blobs {
+			_, versionedHash, err := shared.BlobCommitment(blob.Data)
+			if err != nil {
+				return fmt.Errorf("sidecar for slot %d: %w", sidecar.BeaconBlockSlot, err)
+			}
+			if wanted[versionedHash] {
+				found[versionedHash] = blob.Data
+			}
+		}
+	}
+
+	var written uint64
+	for i, h := range manifest.Blobs {
+		blob, ok := found[h]
+		if !ok {
+			return fmt.Errorf("blob %d (versioned hash %v) not found in the requested slots", i, h)
+		}
+		n, err := io.Copy(w, shared.NewBlobReader(bytes.NewReader(blob)))
+		if err != nil {
+			return fmt.Errorf("blob %d (versioned hash %v): %w", i, h, err)
+		}
+		written += uint64(n)
 	}
-	if code != 0 {
-		return nil, errors.New(errMsg)
+	if written != manifest.Length {
+		return fmt.Errorf("reassembled %d bytes, manifest expects %d", written, manifest.Length)
 	}
-	sidecar := new(ethpb.BlobsSidecar)
-	err = encoding.DecodeWithMaxLength(stream, sidecar)
-	return sidecar, err
+	return nil
 }
@@ -26,6 +26,8 @@ bootnode_enr=`cat /data/bootnode/enr.dat`
 echo "- $bootnode_enr" > /config_data/custom_config_data/boot_enr.yaml
 # overwrite the static bootnode file too
 echo "- $bootnode_enr" > /config_data/custom_config_data/boot_enr.txt
+# publish it to the host so the test setup and download tool can discover peers
+cp /config_data/custom_config_data/boot_enr.yaml /gen-configs/custom_config_data/boot_enr.yaml
 
 echo "Generated bootnode enr - $bootnode_enr"
 
@@ -0,0 +1,131 @@
+// Package accounts provides the keys of the accounts funded in the devnet genesis.
+package accounts
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Inphi/eip4844-interop/shared"
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// FundedAccounts is the number of accounts derived from the mnemonic that are premined in
+// shared/genesis-generator-configs/el/genesis-config.yaml
+const FundedAccounts = 6
+
+type Account struct {
+	Address common.Address
+	Key     *ecdsa.PrivateKey
+	// Path is the derivation path of the key, empty if the key wasn't derived from a mnemonic
+	Path string
+}
+
+func NewAccount(key *ecdsa.PrivateKey) *Account {
+	return &Account{
+		Address: crypto.PubkeyToAddress(key.PublicKey),
+		Key:     key,
+	}
+}
+
+// Default returns the account of shared.PrivateKey
+func Default() (*Account, error) {
+	key, err := crypto.HexToECDSA(shared.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewAccount(key), nil
+}
+
+// Funded returns the accounts derived from the devnet mnemonic that are funded in the genesis
+func Funded() ([]*Account, error) {
+	mnemonic, err := Mnemonic()
+	if err != nil {
+		return nil, err
+	}
+	return Derive(mnemonic, FundedAccounts)
+}
+
+// Derive returns the first n accounts of the mnemonic, using the default BIP-44 paths m/44'/60'/0'/0/i
+func Derive(mnemonic string, n int) ([]*Account, error) {
+	seed := Seed(mnemonic, "")
+	next := gethaccounts.DefaultIterator(gethaccounts.DefaultBaseDerivationPath)
+	accounts := make([]*Account, n)
+	for i := range accounts {
+		path := next()
+		key, err := DeriveKey(seed, path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: derive %s", err, path)
+		}
+		accounts[i] = NewAccount(key)
+		accounts[i].Path = path.String()
+	}
+	return accounts, nil
+}
+
+// Mnemonic returns the EL_AND_CL_MNEMONIC used by the genesis generator.
+// The environment variable takes precedence over the value in values.env.
+func Mnemonic() (string, error) {
+	if m := os.Getenv("EL_AND_CL_MNEMONIC"); m != "" {
+		return m, nil
+	}
+	path := shared.GenesisGeneratorValuesFilepath()
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "export ")
+		if strings.HasPrefix(line, "EL_AND_CL_MNEMONIC=") {
+			return strings.Trim(strings.TrimPrefix(line, "EL_AND_CL_MNEMONIC="), `"'`), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("EL_AND_CL_MNEMONIC not found in %s", path)
+}
+
+// LoadKeystore decrypts a geth keystore file
+func LoadKeystore(path, passphrase string) (*Account, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keystore.DecryptKey(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to decrypt keystore %s", err, path)
+	}
+	return NewAccount(key.PrivateKey), nil
+}
+
+// Select picks an account by index, or by address if selector is a hex address
+func Select(accounts []*Account, selector string) (*Account, error) {
+	if common.IsHexAddress(selector) {
+		addr := common.HexToAddress(selector)
+		for _, a := range accounts {
+			if a.Address == addr {
+				return a, nil
+			}
+		}
+		return nil, fmt.Errorf("account %v not found", addr)
+	}
+	i, err := strconv.Atoi(selector)
+	if err != nil {
+		return nil, errors.New("account must be an index or an address")
+	}
+	if i < 0 || i >= len(accounts) {
+		return nil, fmt.Errorf("account index %d out of range [0, %d)", i, len(accounts))
+	}
+	return accounts[i], nil
+}
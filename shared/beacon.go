@@ -0,0 +1,97 @@
+package shared
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrBlockNotFound is returned when the beacon node has no block carrying a given execution payload
+var ErrBlockNotFound = errors.New("beacon block not found")
+
+// GetSlotForExecutionBlock returns the slot of the beacon block whose execution payload is the given execution block
+func GetSlotForExecutionBlock(beaconAPI string, header *types.Header) (uint64, error) {
+	slot, err := GetSlotAtTime(beaconAPI, header.Time)
+	if err != nil {
+		return 0, err
+	}
+	var block struct {
+		Data struct {
+			Message struct {
+				Body struct {
+					ExecutionPayload struct {
+						BlockHash common.Hash `json:"block_hash"`
+					} `json:"execution_payload"`
+				} `json:"body"`
+			} `json:"message"`
+		} `json:"data"`
+	}
+	err = getBeaconJSON(beaconAPI, fmt.Sprintf("/eth/v2/beacon/blocks/%d", slot), &block)
+	if errors.Is(err, errNotFound) {
+		return 0, fmt.Errorf("%w at slot %d", ErrBlockNotFound, slot)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if h := block.Data.Message.Body.ExecutionPayload.BlockHash; h != header.Hash() {
+		// the execution block was reorged out
+		return 0, fmt.Errorf("%w: block at slot %d has execution block %v, expected %v", ErrBlockNotFound, slot, h, header.Hash())
+	}
+	return slot, nil
+}
+
+// GetSlotAtTime returns the beacon chain slot that starts at the given unix timestamp,
+// which is the slot of the beacon block carrying an execution payload with that timestamp
+func GetSlotAtTime(beaconAPI string, timestamp uint64) (uint64, error) {
+	var genesis struct {
+		Data struct {
+			GenesisTime string `json:"genesis_time"`
+		} `json:"data"`
+	}
+	if err := getBeaconJSON(beaconAPI, "/eth/v1/beacon/genesis", &genesis); err != nil {
+		return 0, err
+	}
+	var spec struct {
+		Data struct {
+			SecondsPerSlot string `json:"SECONDS_PER_SLOT"`
+		} `json:"data"`
+	}
+	if err := getBeaconJSON(beaconAPI, "/eth/v1/config/spec", &spec); err != nil {
+		return 0, err
+	}
+
+	genesisTime, err := strconv.ParseUint(genesis.Data.GenesisTime, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid genesis time %q: %w", genesis.Data.GenesisTime, err)
+	}
+	secondsPerSlot, err := strconv.ParseUint(spec.Data.SecondsPerSlot, 10, 64)
+	if err != nil || secondsPerSlot == 0 {
+		return 0, fmt.Errorf("invalid SECONDS_PER_SLOT %q", spec.Data.SecondsPerSlot)
+	}
+	if timestamp < genesisTime {
+		return 0, fmt.Errorf("timestamp %d is before genesis (%d)", timestamp, genesisTime)
+	}
+	return (timestamp - genesisTime) / secondsPerSlot, nil
+}
+
+var errNotFound = errors.New("not found")
+
+func getBeaconJSON(beaconAPI, path string, v interface{}) error {
+	r, err := http.Get(beaconAPI + path)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", path, errNotFound)
+	}
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", path, r.Status)
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
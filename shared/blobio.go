@@ -0,0 +1,167 @@
+package shared
+
+import (
+	"errors"
+	"io"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// BlobWriter encodes a payload into blobs as it is written.
+// Encoded blobs are written to the underlying writer as flattened blobs of FieldElementsPerBlob*32 bytes,
+// byte-for-byte identical to the output of EncodeBlobs. At most one blob of payload is buffered.
+type BlobWriter struct {
+	w       io.Writer
+	version byte
+	buf     []byte
+	blobs   int
+	closed  bool
+}
+
+func NewBlobWriter(w io.Writer) *BlobWriter {
+	return newBlobWriter(w, BlobCodecVersion)
+}
+
+// NewDenseBlobWriter returns a BlobWriter that produces the same output as EncodeBlobsDense
+func NewDenseBlobWriter(w io.Writer) *BlobWriter {
+	return newBlobWriter(w, DenseBlobCodecVersion)
+}
+
+func newBlobWriter(w io.Writer, version byte) *BlobWriter {
+	return &BlobWriter{
+		w:       w,
+		version: version,
+		buf:     make([]byte, 0, blobDataCapacity(version)),
+	}
+}
+
+func (bw *BlobWriter) Write(p []byte) (int, error) {
+	if bw.closed {
+		return 0, errors.New("write to closed BlobWriter")
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(bw.buf) == cap(bw.buf) {
+			if err := bw.flush(); err != nil {
+				return n, err
+			}
+		}
+		c := copy(bw.buf[len(bw.buf):cap(bw.buf)], p)
+		bw.buf = bw.buf[:len(bw.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Close writes the final blob. A blob is always written, even if the payload is empty.
+func (bw *BlobWriter) Close() error {
+	if bw.closed {
+		return nil
+	}
+	bw.closed = true
+	if len(bw.buf) > 0 || bw.blobs == 0 {
+		return bw.flush()
+	}
+	return nil
+}
+
+// Blobs returns the number of blobs written so far
+func (bw *BlobWriter) Blobs() int {
+	return bw.blobs
+}
+
+func (bw *BlobWriter) flush() error {
+	blob := encodeBlob(bw.buf, bw.version)
+	for i := range blob {
+		if _, err := bw.w.Write(blob[i][:]); err != nil {
+			return err
+		}
+	}
+	bw.buf = bw.buf[:0]
+	bw.blobs++
+	return nil
+}
+
+// BlobReader decodes flattened blobs read from the underlying reader, one blob at a time.
+type BlobReader struct {
+	r       io.Reader
+	blob    []byte
+	pending []byte
+	err     error
+}
+
+func NewBlobReader(r io.Reader) *BlobReader {
+	return &BlobReader{
+		r:    r,
+		blob: make([]byte, flatBlobSize),
+	}
+}
+
+func (br *BlobReader) Read(p []byte) (int, error) {
+	for len(br.pending) == 0 {
+		if br.err != nil {
+			return 0, br.err
+		}
+		if _, err := io.ReadFull(br.r, br.blob); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = errors.New("invalid blob encoding: truncated blob")
+			}
+			br.err = err
+			continue
+		}
+		data, err := decodeFlatBlob(br.blob)
+		if err != nil {
+			br.err = err
+			continue
+		}
+		br.pending = data
+	}
+	n := copy(p, br.pending)
+	br.pending = br.pending[n:]
+	return n, nil
+}
+
+// ReadBlobs encodes everything read from r into blobs
+func ReadBlobs(r io.Reader) (types.Blobs, error) {
+	return readBlobs(r, BlobCodecVersion)
+}
+
+// ReadBlobsDense is like ReadBlobs, but packs the blobs densely
+func ReadBlobsDense(r io.Reader) (types.Blobs, error) {
+	return readBlobs(r, DenseBlobCodecVersion)
+}
+
+func readBlobs(r io.Reader, version byte) (types.Blobs, error) {
+	sink := new(blobSink)
+	bw := newBlobWriter(sink, version)
+	if _, err := io.Copy(bw, r); err != nil {
+		return nil, err
+	}
+	if err := bw.Close(); err != nil {
+		return nil, err
+	}
+	return sink.blobs, nil
+}
+
+// blobSink collects flattened blobs written to it into types.Blobs
+type blobSink struct {
+	blobs  types.Blobs
+	offset int
+}
+
+func (s *blobSink) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if s.offset == 0 {
+			s.blobs = append(s.blobs, types.Blob{})
+		}
+		blob := &s.blobs[len(s.blobs)-1]
+		elem := s.offset / 32
+		c := copy(blob[elem][s.offset%32:], p)
+		s.offset = (s.offset + c) % flatBlobSize
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
@@ -1,61 +1,225 @@
 package shared
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/params"
 )
 
+// Blobs produced by EncodeBlobs are framed. The first field element of every blob holds a header:
+//
+//	magic (4 bytes) | version (1 byte) | payload length (8 bytes, big-endian)
+//
+// followed by the payload, starting at the second field element.
+// Each blob is framed independently so that blobs can be decoded one at a time.
+//
+// Version 1 packs the payload 31 bytes per field element, leaving the most significant byte empty.
+// Version 2 (dense) packs 127 bytes into each group of 4 field elements, storing the spare 3 bytes
+// 6 bits at a time in the most significant byte of each element. Every field element stays below 2^254,
+// and hence below the BLS12-381 scalar modulus.
+const (
+	// BlobCodecVersion is the framing version written by EncodeBlobs
+	BlobCodecVersion = 1
+	// DenseBlobCodecVersion is the framing version written by EncodeBlobsDense
+	DenseBlobCodecVersion = 2
+
+	// BlobDataCapacity is the number of payload bytes that fit in a single framed blob
+	BlobDataCapacity = (params.FieldElementsPerBlob - 1) * fieldElementDataSize
+	// DenseBlobDataCapacity is the number of payload bytes that fit in a single densely packed blob
+	DenseBlobDataCapacity = (params.FieldElementsPerBlob - 1) / denseGroupElements * denseGroupSize
+
+	fieldElementDataSize = 31
+	flatBlobSize         = params.FieldElementsPerBlob * 32
+	blobHeaderSize       = 4 + 1 + 8
+
+	denseGroupElements = 4
+	denseGroupSize     = denseGroupElements*fieldElementDataSize + 3
+)
+
+var blobMagic = [4]byte{0xb1, 0x0b, 0xc0, 0xde}
+
+// blsModulus is the BLS12-381 scalar field modulus, little-endian
+var blsModulus = [32]byte{
+	0x01, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xfe, 0x5b, 0xfe, 0xff, 0x02, 0xa4, 0xbd, 0x53,
+	0x05, 0xd8, 0xa1, 0x09, 0x08, 0xd8, 0x39, 0x33, 0x48, 0x7d, 0x9d, 0x29, 0x53, 0xa7, 0xed, 0x73,
+}
+
 func EncodeBlobs(data []byte) types.Blobs {
-	blobs := []types.Blob{{}}
-	blobIndex := 0
-	fieldIndex := -1
-	for i := 0; i < len(data); i += 31 {
-		fieldIndex++
-		if fieldIndex == params.FieldElementsPerBlob {
-			blobs = append(blobs, types.Blob{})
-			blobIndex++
-			fieldIndex = 0
+	return encodeBlobs(data, BlobCodecVersion)
+}
+
+// EncodeBlobsDense is like EncodeBlobs, but also uses the spare bits of every field element
+func EncodeBlobsDense(data []byte) types.Blobs {
+	return encodeBlobs(data, DenseBlobCodecVersion)
+}
+
+func encodeBlobs(data []byte, version byte) types.Blobs {
+	capacity := blobDataCapacity(version)
+	var blobs types.Blobs
+	for {
+		n := len(data)
+		if n > capacity {
+			n = capacity
+		}
+		blobs = append(blobs, encodeBlob(data[:n], version))
+		data = data[n:]
+		if len(data) == 0 {
+			break
 		}
-		max := i + 31
+	}
+	return blobs
+}
+
+func blobDataCapacity(version byte) int {
+	if version == DenseBlobCodecVersion {
+		return DenseBlobDataCapacity
+	}
+	return BlobDataCapacity
+}
+
+func encodeBlob(data []byte, version byte) types.Blob {
+	var blob types.Blob
+	copy(blob[0][:], blobHeader(version, uint64(len(data))))
+	if version == DenseBlobCodecVersion {
+		encodeDense(blob[1:], data)
+		return blob
+	}
+	fieldIndex := 1
+	for i := 0; i < len(data); i += fieldElementDataSize {
+		max := i + fieldElementDataSize
 		if max > len(data) {
 			max = len(data)
 		}
-		copy(blobs[blobIndex][fieldIndex][:], data[i:max])
+		copy(blob[fieldIndex][:], data[i:max])
+		fieldIndex++
 	}
-	return blobs
+	return blob
+}
+
+func encodeDense(elems []types.BLSFieldElement, data []byte) {
+	var group [denseGroupSize]byte
+	for g := 0; len(data) > 0; g++ {
+		n := copy(group[:], data)
+		for i := n; i < len(group); i++ {
+			group[i] = 0
+		}
+		data = data[n:]
+
+		spare := uint32(group[124]) | uint32(group[125])<<8 | uint32(group[126])<<16
+		for k := 0; k < denseGroupElements; k++ {
+			elem := &elems[g*denseGroupElements+k]
+			copy(elem[:fieldElementDataSize], group[k*fieldElementDataSize:])
+			elem[fieldElementDataSize] = byte(spare>>(6*k)) & 0x3f
+		}
+	}
+}
+
+func decodeDense(elems [][]byte) []byte {
+	data := make([]byte, 0, len(elems)/denseGroupElements*denseGroupSize)
+	for g := 0; g+denseGroupElements <= len(elems); g += denseGroupElements {
+		var spare uint32
+		for k := 0; k < denseGroupElements; k++ {
+			elem := elems[g+k]
+			data = append(data, elem[:fieldElementDataSize]...)
+			spare |= uint32(elem[fieldElementDataSize]&0x3f) << (6 * k)
+		}
+		data = append(data, byte(spare), byte(spare>>8), byte(spare>>16))
+	}
+	return data
+}
+
+func blobHeader(version byte, length uint64) []byte {
+	header := make([]byte, blobHeaderSize)
+	copy(header, blobMagic[:])
+	header[4] = version
+	binary.BigEndian.PutUint64(header[5:], length)
+	return header
+}
+
+// parseBlobHeader returns the codec version and payload length of a framed blob, given the contents of its first field element.
+// ok is false if the field element does not carry a header, in which case the blob uses the legacy encoding.
+func parseBlobHeader(elem []byte) (version byte, length uint64, ok bool) {
+	if !bytes.Equal(elem[:len(blobMagic)], blobMagic[:]) {
+		return 0, 0, false
+	}
+	version = elem[4]
+	if version != BlobCodecVersion && version != DenseBlobCodecVersion {
+		return 0, 0, false
+	}
+	return version, binary.BigEndian.Uint64(elem[5:blobHeaderSize]), true
 }
 
 // DecodeFlatBlob decodes a flattened blob
 func DecodeFlatBlob(blob []byte) []byte {
-	if len(blob) != params.FieldElementsPerBlob*32 {
-		panic("invalid blob encoding")
+	data, err := decodeFlatBlob(blob)
+	if err != nil {
+		panic(err)
 	}
-	var data []byte
+	return data
+}
 
-	// XXX: the following removes trailing 0s in each field element (see EncodeBlobs), which could be unexpected for certain blobs
-	j := 0
-	for i := 0; i < params.FieldElementsPerBlob; i++ {
-		data = append(data, blob[j:j+31]...)
-		j += 32
+func decodeFlatBlob(blob []byte) ([]byte, error) {
+	if len(blob) != flatBlobSize {
+		return nil, errors.New("invalid blob encoding")
 	}
+	return decodeBlob(splitFlatBlob(blob))
+}
 
-	i := len(data) - 1
-	for ; i >= 0; i-- {
-		if data[i] != 0x00 {
-			break
-		}
+func splitFlatBlob(blob []byte) [][]byte {
+	elems := make([][]byte, len(blob)/32)
+	for i := range elems {
+		elems[i] = blob[i*32 : (i+1)*32]
 	}
-	data = data[:i+1]
-	return data
+	return elems
 }
 
+// DecodeBlob decodes a blob given as a list of field elements
 func DecodeBlob(blob [][]byte) []byte {
+	data, err := decodeBlob(blob)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func decodeBlob(blob [][]byte) ([]byte, error) {
+	if len(blob) == 0 {
+		return nil, nil
+	}
+	version, length, framed := parseBlobHeader(blob[0])
+	if !framed {
+		return decodeLegacyBlob(blob), nil
+	}
+	if length > uint64(blobDataCapacity(version)) {
+		return nil, errors.New("invalid blob encoding: payload length exceeds blob capacity")
+	}
+
+	var data []byte
+	if version == DenseBlobCodecVersion {
+		data = decodeDense(blob[1:])
+	} else {
+		for _, b := range blob[1:] {
+			data = append(data, b[0:fieldElementDataSize]...)
+		}
+	}
+	if length > uint64(len(data)) {
+		return nil, errors.New("invalid blob encoding: truncated blob")
+	}
+	return data[:length], nil
+}
+
+// decodeLegacyBlob decodes blobs written before framing was introduced.
+// The payload length is unknown, so trailing zeros are stripped, which could be unexpected for certain blobs.
+func decodeLegacyBlob(blob [][]byte) []byte {
 	var data []byte
 	for _, b := range blob {
-		data = append(data, b[0:31]...)
+		data = append(data, b[0:fieldElementDataSize]...)
 	}
 
-	// XXX: the following removes trailing 0s, which could be unexpected for certain blobs
 	i := len(data) - 1
 	for ; i >= 0; i-- {
 		if data[i] != 0x00 {
@@ -65,3 +229,41 @@ func DecodeBlob(blob [][]byte) []byte {
 	data = data[:i+1]
 	return data
 }
+
+// InvalidBlobError lists the field elements of a blob that are not canonical,
+// i.e. that are not below the BLS12-381 scalar modulus
+type InvalidBlobError struct {
+	FieldElements []int
+}
+
+func (e *InvalidBlobError) Error() string {
+	return fmt.Sprintf("blob contains %d non-canonical field elements: %v", len(e.FieldElements), e.FieldElements)
+}
+
+// ValidateBlob checks that a flattened blob has the expected size and that every field element is canonical.
+// The returned error is an *InvalidBlobError if the size is correct but some field elements would be rejected by clients.
+func ValidateBlob(blob []byte) error {
+	if len(blob) != flatBlobSize {
+		return fmt.Errorf("invalid blob size: %d, expected %d", len(blob), flatBlobSize)
+	}
+	var invalid []int
+	for i, elem := range splitFlatBlob(blob) {
+		if !isCanonicalFieldElement(elem) {
+			invalid = append(invalid, i)
+		}
+	}
+	if len(invalid) != 0 {
+		return &InvalidBlobError{FieldElements: invalid}
+	}
+	return nil
+}
+
+// isCanonicalFieldElement reports whether the little-endian field element is below the BLS12-381 scalar modulus
+func isCanonicalFieldElement(elem []byte) bool {
+	for i := len(blsModulus) - 1; i >= 0; i-- {
+		if elem[i] != blsModulus[i] {
+			return elem[i] < blsModulus[i]
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package shared
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// testPayload returns n bytes of data without any zero bytes, followed by zeros zero bytes
+func testPayload(n, zeros int) []byte {
+	data := make([]byte, n+zeros)
+	for i := 0; i < n; i++ {
+		data[i] = byte(i%255) + 1
+	}
+	return data
+}
+
+func flattenBlobs(blobs types.Blobs) []byte {
+	var flat []byte
+	for i := range blobs {
+		for j := range blobs[i] {
+			flat = append(flat, blobs[i][j][:]...)
+		}
+	}
+	return flat
+}
+
+// decodeBlobs decodes every blob on its own, and the blobs as a stream, and checks that both decodings agree
+func decodeBlobs(t *testing.T, blobs types.Blobs) []byte {
+	t.Helper()
+	var data []byte
+	for i := range blobs {
+		d, err := decodeFlatBlob(flattenBlobs(blobs[i : i+1]))
+		if err != nil {
+			t.Fatalf("blob %d: %v", i, err)
+		}
+		data = append(data, d...)
+	}
+	streamed, err := io.ReadAll(NewBlobReader(bytes.NewReader(flattenBlobs(blobs))))
+	if err != nil {
+		t.Fatalf("BlobReader: %v", err)
+	}
+	if !bytes.Equal(streamed, data) {
+		t.Fatalf("BlobReader returned %d bytes, decoding blob by blob returned %d bytes", len(streamed), len(data))
+	}
+	return data
+}
+
+func TestEncodeDecodeBlobs(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		blobs int
+	}{
+		{"empty", nil, 1},
+		{"one byte", []byte{0x42}, 1},
+		{"single zero byte", []byte{0x00}, 1},
+		{"trailing zeros", append([]byte("EKANS"), 0x00, 0x00, 0x00), 1},
+		{"only zeros", make([]byte, 100), 1},
+		{"field element boundary", testPayload(fieldElementDataSize, 0), 1},
+		{"zero at field element boundary", testPayload(fieldElementDataSize-1, 1), 1},
+		{"capacity", testPayload(BlobDataCapacity, 0), 1},
+		{"capacity ending in zeros", testPayload(BlobDataCapacity-10, 10), 1},
+		{"capacity plus one", testPayload(BlobDataCapacity+1, 0), 2},
+		{"capacity plus one zero", testPayload(BlobDataCapacity, 1), 2},
+		{"multiple blobs", testPayload(2*BlobDataCapacity+1000, 0), 3},
+		{"multiple blobs ending in zeros", testPayload(2*BlobDataCapacity, 1000), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs := EncodeBlobs(tt.data)
+			if len(blobs) != tt.blobs {
+				t.Fatalf("got %d blobs, want %d", len(blobs), tt.blobs)
+			}
+			if got := decodeBlobs(t, blobs); !bytes.Equal(got, tt.data) {
+				t.Errorf("got %d bytes, want %d bytes", len(got), len(tt.data))
+			}
+			streamed, err := ReadBlobs(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(flattenBlobs(streamed), flattenBlobs(blobs)) {
+				t.Error("ReadBlobs differs from EncodeBlobs")
+			}
+		})
+	}
+}
+
+// fill returns n copies of b
+func fill(b byte, n int) []byte {
+	return bytes.Repeat([]byte{b}, n)
+}
+
+func TestEncodeDecodeBlobsDense(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		blobs int
+	}{
+		{"empty", nil, 1},
+		{"one byte", []byte{0x42}, 1},
+		{"trailing zeros", append([]byte("EKANS"), 0x00, 0x00, 0x00), 1},
+		{"field elements of a group", testPayload(4*fieldElementDataSize, 0), 1},
+		{"group minus one", testPayload(denseGroupSize-1, 0), 1},
+		{"group", testPayload(denseGroupSize, 0), 1},
+		{"group plus one", testPayload(denseGroupSize+1, 0), 1},
+		{"group ending in zeros", testPayload(denseGroupSize-3, 3), 1},
+		{"two groups", testPayload(2*denseGroupSize, 0), 1},
+		{"spare bytes all ones", fill(0xff, denseGroupSize), 1},
+		{"capacity", testPayload(DenseBlobDataCapacity, 0), 1},
+		{"capacity of ones", fill(0xff, DenseBlobDataCapacity), 1},
+		{"capacity ending in zeros", testPayload(DenseBlobDataCapacity-10, 10), 1},
+		{"capacity plus one", testPayload(DenseBlobDataCapacity+1, 0), 2},
+		{"multiple blobs", testPayload(2*DenseBlobDataCapacity+1000, 0), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs := EncodeBlobsDense(tt.data)
+			if len(blobs) != tt.blobs {
+				t.Fatalf("got %d blobs, want %d", len(blobs), tt.blobs)
+			}
+			for i := range blobs {
+				if err := ValidateBlob(flattenBlobs(blobs[i : i+1])); err != nil {
+					t.Fatalf("blob %d: %v", i, err)
+				}
+				for j := range blobs[i] {
+					if top := blobs[i][j][fieldElementDataSize]; top > 0x3f {
+						t.Fatalf("blob %d field element %d uses more than 254 bits: top byte %#x", i, j, top)
+					}
+				}
+			}
+			if got := decodeBlobs(t, blobs); !bytes.Equal(got, tt.data) {
+				t.Errorf("got %d bytes, want %d bytes", len(got), len(tt.data))
+			}
+			streamed, err := ReadBlobsDense(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(flattenBlobs(streamed), flattenBlobs(blobs)) {
+				t.Error("ReadBlobsDense differs from EncodeBlobsDense")
+			}
+		})
+	}
+}
+
+func TestValidateBlob(t *testing.T) {
+	// withElements returns a blob of zeros with the given field elements set to elem
+	withElements := func(elem []byte, indices ...int) []byte {
+		flat := make([]byte, flatBlobSize)
+		for _, i := range indices {
+			copy(flat[i*32:(i+1)*32], elem)
+		}
+		return flat
+	}
+	belowModulus := blsModulus
+	belowModulus[0]--
+
+	tests := []struct {
+		name    string
+		blob    []byte
+		invalid []int
+		err     bool
+	}{
+		{"zeros", make([]byte, flatBlobSize), nil, false},
+		{"encoded blob", flattenBlobs(EncodeBlobs(fill(0xff, BlobDataCapacity))), nil, false},
+		{"modulus minus one", withElements(belowModulus[:], 0, 4095), nil, false},
+		{"modulus", withElements(blsModulus[:], 7), []int{7}, true},
+		{"above modulus", withElements(fill(0xff, 32), 0, 1, 4095), []int{0, 1, 4095}, true},
+		{"short blob", make([]byte, flatBlobSize-32), nil, true},
+		{"long blob", make([]byte, flatBlobSize+1), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBlob(tt.blob)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %v", err, tt.err)
+			}
+			var invalidErr *InvalidBlobError
+			if errors.As(err, &invalidErr) {
+				if !reflect.DeepEqual(invalidErr.FieldElements, tt.invalid) {
+					t.Errorf("got invalid field elements %v, want %v", invalidErr.FieldElements, tt.invalid)
+				}
+			} else if tt.invalid != nil {
+				t.Errorf("got error %v, want an InvalidBlobError", err)
+			}
+		})
+	}
+}
+
+// legacyBlob builds a blob the way it was encoded before framing, 31 bytes per field element from the first one
+func legacyBlob(data []byte) []byte {
+	flat := make([]byte, flatBlobSize)
+	for i := 0; i*fieldElementDataSize < len(data); i++ {
+		copy(flat[i*32:i*32+fieldElementDataSize], data[i*fieldElementDataSize:])
+	}
+	return flat
+}
+
+func TestDecodeLegacyBlob(t *testing.T) {
+	tests := []struct {
+		name string
+		blob []byte
+		want []byte
+	}{
+		{"empty", legacyBlob(nil), []byte{}},
+		{"short", legacyBlob([]byte("EKANS")), []byte("EKANS")},
+		{"several field elements", legacyBlob(testPayload(100, 0)), testPayload(100, 0)},
+		{"trailing zeros are lost", legacyBlob(append([]byte("EKANS"), 0x00, 0x00)), []byte("EKANS")},
+		{
+			"magic with an unknown version",
+			legacyBlob(append(blobMagic[:], 0x09, 0x01)),
+			append(blobMagic[:], 0x09, 0x01),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeFlatBlob(tt.blob)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if got := DecodeBlob(splitFlatBlob(tt.blob)); !bytes.Equal(got, tt.want) {
+				t.Errorf("DecodeBlob: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalidBlob(t *testing.T) {
+	framed := func(version byte, length uint64) []byte {
+		flat := make([]byte, flatBlobSize)
+		copy(flat, blobHeader(version, length))
+		return flat
+	}
+	tests := []struct {
+		name string
+		blob []byte
+	}{
+		{"length over capacity", framed(BlobCodecVersion, BlobDataCapacity+1)},
+		{"huge length", framed(BlobCodecVersion, 1<<63)},
+		{"dense length over capacity", framed(DenseBlobCodecVersion, DenseBlobDataCapacity+1)},
+		{"short blob", make([]byte, flatBlobSize-1)},
+		{"long blob", make([]byte, flatBlobSize+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeFlatBlob(tt.blob); err == nil {
+				t.Error("decodeFlatBlob succeeded")
+			}
+			if _, err := io.ReadAll(NewBlobReader(bytes.NewReader(tt.blob))); err == nil {
+				t.Error("BlobReader succeeded")
+			}
+		})
+	}
+}
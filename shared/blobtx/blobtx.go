@@ -0,0 +1,176 @@
+// Package blobtx builds, signs and sends EIP-4844 blob transactions.
+package blobtx
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/holiman/uint256"
+	"github.com/protolambda/ztyp/view"
+)
+
+// Builder creates blob transactions. Options given to NewBuilder apply to every transaction,
+// and can be overridden by the options given to Build.
+type Builder struct {
+	client *ethclient.Client
+	opts   []Option
+}
+
+func NewBuilder(client *ethclient.Client, opts ...Option) *Builder {
+	return &Builder{
+		client: client,
+		opts:   opts,
+	}
+}
+
+// Build creates and signs a blob transaction, wrapped with its blobs, commitments and aggregated proof
+func (b *Builder) Build(ctx context.Context, opts ...Option) (*types.Transaction, error) {
+	o := defaultOptions()
+	for _, opt := range b.opts {
+		opt(&o)
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if len(o.blobs) == 0 {
+		return nil, ErrNoBlobs
+	}
+	if len(o.blobs) > shared.MaxBlobsPerTx {
+		return nil, ErrTooManyBlobs
+	}
+
+	key := o.key
+	if key == nil {
+		var err error
+		key, err = crypto.HexToECDSA(shared.PrivateKey)
+		if err != nil {
+			return nil, stepError(StepSign, err)
+		}
+	}
+
+	chainID := o.chainID
+	if chainID == nil {
+		if b.client == nil {
+			return nil, stepError(StepChainID, ErrNoClient)
+		}
+		var err error
+		chainID, err = b.client.ChainID(ctx)
+		if err != nil {
+			return nil, stepError(StepChainID, err)
+		}
+	}
+
+	var nonce uint64
+	if o.nonce != nil {
+		nonce = *o.nonce
+	} else {
+		if b.client == nil {
+			return nil, stepError(StepNonce, ErrNoClient)
+		}
+		var err error
+		nonce, err = b.client.PendingNonceAt(ctx, crypto.PubkeyToAddress(key.PublicKey))
+		if err != nil {
+			return nil, stepError(StepNonce, err)
+		}
+	}
+
+	fees := o.fees
+	if fees == nil {
+		if b.client == nil {
+			return nil, stepError(StepFees, ErrNoClient)
+		}
+		var err error
+		fees, err = shared.EstimateBlobTxFees(ctx, b.client, o.feeMultiplier)
+		if err != nil {
+			return nil, stepError(StepFees, err)
+		}
+	}
+
+	commitments, versionedHashes, aggregatedProof, err := o.blobs.ComputeCommitmentsAndAggregatedProof()
+	if err != nil {
+		return nil, stepError(StepCommitments, err)
+	}
+
+	var chainIDView, gasFeeCap, gasTipCap, maxFeePerDataGas, value view.Uint256View
+	if err := toUint256(&chainIDView, chainID); err != nil {
+		return nil, stepError(StepChainID, err)
+	}
+	if err := toUint256(&gasFeeCap, fees.GasFeeCap); err != nil {
+		return nil, stepError(StepFees, fmt.Errorf("gas fee cap: %w", err))
+	}
+	if err := toUint256(&gasTipCap, fees.GasTipCap); err != nil {
+		return nil, stepError(StepFees, fmt.Errorf("gas tip cap: %w", err))
+	}
+	if err := toUint256(&maxFeePerDataGas, fees.MaxFeePerDataGas); err != nil {
+		return nil, stepError(StepFees, fmt.Errorf("max fee per data gas: %w", err))
+	}
+	if err := toUint256(&value, o.value); err != nil {
+		return nil, stepError(StepValue, err)
+	}
+
+	to := o.to
+	txData := types.SignedBlobTx{
+		Message: types.BlobTxMessage{
+			ChainID:             chainIDView,
+			Nonce:               view.Uint64View(nonce),
+			Gas:                 view.Uint64View(o.gas),
+			GasFeeCap:           gasFeeCap,
+			GasTipCap:           gasTipCap,
+			MaxFeePerDataGas:    maxFeePerDataGas,
+			Value:               value,
+			To:                  types.AddressOptionalSSZ{Address: (*types.AddressSSZ)(&to)},
+			BlobVersionedHashes: versionedHashes,
+		},
+	}
+
+	wrapData := types.BlobTxWrapData{
+		BlobKzgs:           commitments,
+		Blobs:              o.blobs,
+		KzgAggregatedProof: aggregatedProof,
+	}
+	tx := types.NewTx(&txData, types.WithTxWrapData(&wrapData))
+	tx, err = types.SignTx(tx, types.NewDankSigner(chainID), key)
+	if err != nil {
+		return nil, stepError(StepSign, err)
+	}
+	return tx, nil
+}
+
+// toUint256 sets v to x, failing with ErrOverflow if x doesn't fit
+func toUint256(v *view.Uint256View, x *big.Int) error {
+	if x.Sign() < 0 {
+		return fmt.Errorf("%w: %s", ErrOverflow, x)
+	}
+	u, overflow := uint256.FromBig(x)
+	if overflow {
+		return fmt.Errorf("%w: %s", ErrOverflow, x)
+	}
+	*v = view.Uint256View(*u)
+	return nil
+}
+
+// Send submits a signed blob transaction
+func (b *Builder) Send(ctx context.Context, tx *types.Transaction) error {
+	if err := b.client.SendTransaction(ctx, tx); err != nil {
+		return stepError(StepSend, err)
+	}
+	return nil
+}
+
+// SendAndWait submits a signed blob transaction and waits for it to be included
+func (b *Builder) SendAndWait(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	if err := b.Send(ctx, tx); err != nil {
+		return nil, err
+	}
+	receipt, err := shared.WaitForReceipt(ctx, b.client, tx.Hash())
+	if err != nil {
+		return nil, stepError(StepReceipt, err)
+	}
+	return receipt, nil
+}
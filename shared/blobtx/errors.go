@@ -0,0 +1,52 @@
+package blobtx
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoBlobs      = errors.New("blob transaction has no blobs")
+	ErrTooManyBlobs = errors.New("blob transaction has too many blobs")
+	ErrNoClient     = errors.New("option is not set and no client is available to retrieve it")
+	ErrOverflow     = errors.New("value is negative or doesn't fit in 256 bits")
+	ErrNonceUsed    = errors.New("nonce was used by another transaction")
+)
+
+// Step identifies the stage of building or sending a blob transaction
+type Step string
+
+const (
+	StepChainID     Step = "chain id"
+	StepNonce       Step = "nonce"
+	StepFees        Step = "fees"
+	StepValue       Step = "value"
+	StepCommitments Step = "kzg commitments"
+	StepSign        Step = "sign"
+	StepSend        Step = "send"
+	StepReceipt     Step = "receipt"
+)
+
+// Error is returned when a step of building or sending a blob transaction fails
+type Error struct {
+	Step Step
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("blobtx %s: %v", e.Step, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsStep reports whether err is an *Error that occurred during step
+func IsStep(err error, step Step) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Step == step
+}
+
+func stepError(step Step, err error) error {
+	return &Error{Step: step, Err: err}
+}
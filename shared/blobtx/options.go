@@ -0,0 +1,100 @@
+package blobtx
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+
+	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DefaultRecipient receives the value of blob transactions unless WithRecipient is used
+var DefaultRecipient = common.HexToAddress("ffb38a7a99e3e2335be83fc74b7faa19d5531243")
+
+const (
+	DefaultGas   = 210000
+	DefaultValue = 12345678
+)
+
+type options struct {
+	chainID       *big.Int
+	to            common.Address
+	value         *big.Int
+	gas           uint64
+	fees          *shared.BlobTxFees
+	feeMultiplier float64
+	nonce         *uint64
+	key           *ecdsa.PrivateKey
+	blobs         types.Blobs
+}
+
+func defaultOptions() options {
+	return options{
+		to:            DefaultRecipient,
+		value:         big.NewInt(DefaultValue),
+		gas:           DefaultGas,
+		feeMultiplier: shared.DefaultFeeMultiplier,
+	}
+}
+
+type Option func(*options)
+
+// WithChainID sets the chain id. By default it is retrieved from the client.
+func WithChainID(chainID *big.Int) Option {
+	return func(o *options) {
+		o.chainID = chainID
+	}
+}
+
+func WithRecipient(to common.Address) Option {
+	return func(o *options) {
+		o.to = to
+	}
+}
+
+func WithValue(value *big.Int) Option {
+	return func(o *options) {
+		o.value = value
+	}
+}
+
+func WithGas(gas uint64) Option {
+	return func(o *options) {
+		o.gas = gas
+	}
+}
+
+// WithFees sets fixed fees. By default fees are estimated with shared.EstimateBlobTxFees.
+func WithFees(fees *shared.BlobTxFees) Option {
+	return func(o *options) {
+		o.fees = fees
+	}
+}
+
+// WithFeeMultiplier sets the safety multiplier used when estimating fees
+func WithFeeMultiplier(multiplier float64) Option {
+	return func(o *options) {
+		o.feeMultiplier = multiplier
+	}
+}
+
+// WithNonce sets the nonce. By default the pending nonce of the sender is used.
+func WithNonce(nonce uint64) Option {
+	return func(o *options) {
+		o.nonce = &nonce
+	}
+}
+
+// WithKey sets the key used to sign the transaction. By default shared.PrivateKey is used.
+func WithKey(key *ecdsa.PrivateKey) Option {
+	return func(o *options) {
+		o.key = key
+	}
+}
+
+func WithBlobs(blobs types.Blobs) Option {
+	return func(o *options) {
+		o.blobs = blobs
+	}
+}
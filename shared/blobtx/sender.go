@@ -0,0 +1,381 @@
+package blobtx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	// DefaultStuckBlocks is the number of blocks after which a pending transaction is replaced
+	DefaultStuckBlocks = 5
+	// DefaultFeeBump is the percentage by which fees are raised when replacing a transaction
+	DefaultFeeBump = 20
+
+	maxSendAttempts = 5
+)
+
+// Sender sends blob transactions from any number of accounts concurrently.
+// It hands out nonces per account, reconciles them with the node when a transaction is rejected
+// because of its nonce, and replaces transactions that remain pending for too long with
+// copies that pay higher execution and data gas fees.
+type Sender struct {
+	builder     *Builder
+	client      *ethclient.Client
+	stuckBlocks uint64
+	feeBump     int64
+	nonceAt     func(ctx context.Context, addr common.Address) (uint64, error)
+
+	mu       sync.Mutex
+	accounts map[common.Address]*accountNonces
+	inflight map[common.Hash]*inflightTx
+}
+
+type accountNonces struct {
+	synced bool
+	next   uint64
+	// released holds nonces below next that were reserved but never used
+	released []uint64
+}
+
+type inflightTx struct {
+	key       *ecdsa.PrivateKey
+	opts      []Option
+	nonce     uint64
+	sentBlock uint64
+	// hashes of every version of the transaction, the last one being the most recent
+	hashes []common.Hash
+	last   *types.Transaction
+	// nonceUsed is set once a replacement is rejected because the nonce is already used
+	nonceUsed bool
+}
+
+type SenderOption func(*Sender)
+
+// WithStuckBlocks sets the number of blocks a transaction may stay pending before it is replaced. 0 disables replacement.
+func WithStuckBlocks(blocks uint64) SenderOption {
+	return func(s *Sender) {
+		s.stuckBlocks = blocks
+	}
+}
+
+// WithFeeBump sets the percentage by which fees are raised when replacing a transaction
+func WithFeeBump(percent int64) SenderOption {
+	return func(s *Sender) {
+		s.feeBump = percent
+	}
+}
+
+// WithLatestNonce syncs nonces from the latest block rather than the pending state, for nodes like ethereumjs
+// that don't support pending nonces
+func WithLatestNonce() SenderOption {
+	return func(s *Sender) {
+		s.nonceAt = func(ctx context.Context, addr common.Address) (uint64, error) {
+			return s.client.NonceAt(ctx, addr, nil)
+		}
+	}
+}
+
+func NewSender(builder *Builder, opts ...SenderOption) *Sender {
+	s := &Sender{
+		builder:     builder,
+		client:      builder.client,
+		stuckBlocks: DefaultStuckBlocks,
+		feeBump:     DefaultFeeBump,
+		accounts:    make(map[common.Address]*accountNonces),
+		inflight:    make(map[common.Hash]*inflightTx),
+	}
+	s.nonceAt = s.client.PendingNonceAt
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Send builds a blob transaction signed by key with the next available nonce and submits it
+func (s *Sender) Send(ctx context.Context, key *ecdsa.PrivateKey, opts ...Option) (*types.Transaction, error) {
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	for attempt := 0; ; attempt++ {
+		nonce, err := s.reserve(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		tx, err := s.builder.Build(ctx, withOptions(opts, WithKey(key), WithNonce(nonce))...)
+		if err != nil {
+			s.release(addr, nonce)
+			return nil, err
+		}
+		bn, err := s.client.BlockNumber(ctx)
+		if err != nil {
+			s.release(addr, nonce)
+			return nil, stepError(StepSend, err)
+		}
+
+		err = s.builder.Send(ctx, tx)
+		if err == nil || isAlreadyKnown(err) {
+			s.track(tx, key, opts, nonce, bn)
+			return tx, nil
+		}
+		switch {
+		case isNonceTooLow(err), isUnderpriced(err):
+			// the nonce was used outside of this sender. resync and try the next one
+			log.Printf("nonce %d of %v is already used (%v), resyncing", nonce, addr, err)
+			if err := s.resync(ctx, addr, nonce+1); err != nil {
+				return nil, err
+			}
+		default:
+			s.release(addr, nonce)
+			return nil, err
+		}
+		if attempt+1 == maxSendAttempts {
+			return nil, err
+		}
+	}
+}
+
+// Wait waits for a transaction submitted through Send to be included, replacing it if it is stuck.
+// The returned receipt may belong to a replacement of tx. If another transaction takes the nonce of tx, the error wraps ErrNonceUsed.
+func (s *Sender) Wait(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	s.mu.Lock()
+	itx, ok := s.inflight[tx.Hash()]
+	s.mu.Unlock()
+	if !ok {
+		return shared.WaitForReceipt(ctx, s.client, tx.Hash())
+	}
+
+	addr := crypto.PubkeyToAddress(itx.key.PublicKey)
+	for {
+		s.mu.Lock()
+		hashes := append([]common.Hash(nil), itx.hashes...)
+		sentBlock := itx.sentBlock
+		nonceUsed := itx.nonceUsed
+		s.mu.Unlock()
+
+		// the nonce is read before the receipts, so that a version included in between isn't mistaken for another tx
+		var nonce uint64
+		if nonceUsed {
+			n, err := s.client.NonceAt(ctx, addr, nil)
+			if err != nil {
+				return nil, stepError(StepReceipt, err)
+			}
+			nonce = n
+		}
+		for _, h := range hashes {
+			receipt, err := s.client.TransactionReceipt(ctx, h)
+			if err == ethereum.NotFound {
+				continue
+			}
+			if err != nil {
+				return nil, stepError(StepReceipt, err)
+			}
+			s.untrack(itx)
+			return receipt, nil
+		}
+		if nonceUsed && nonce > itx.nonce {
+			s.untrack(itx)
+			return nil, stepError(StepReceipt, fmt.Errorf("%w: nonce %d of %v", ErrNonceUsed, itx.nonce, addr))
+		}
+
+		if s.stuckBlocks > 0 {
+			bn, err := s.client.BlockNumber(ctx)
+			if err != nil {
+				return nil, stepError(StepReceipt, err)
+			}
+			if bn >= sentBlock+s.stuckBlocks {
+				if err := s.replace(ctx, itx, bn); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, stepError(StepReceipt, ctx.Err())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// SendAndWait sends a transaction and waits for it, or one of its replacements, to be included
+func (s *Sender) SendAndWait(ctx context.Context, key *ecdsa.PrivateKey, opts ...Option) (*types.Receipt, error) {
+	tx, err := s.Send(ctx, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s.Wait(ctx, tx)
+}
+
+// replace re-sends a stuck transaction with bumped fees
+func (s *Sender) replace(ctx context.Context, itx *inflightTx, bn uint64) error {
+	fees := &shared.BlobTxFees{
+		GasTipCap:        s.bump(itx.last.GasTipCap()),
+		GasFeeCap:        s.bump(itx.last.GasFeeCap()),
+		MaxFeePerDataGas: s.bump(itx.last.MaxFeePerDataGas()),
+	}
+	tx, err := s.builder.Build(ctx, withOptions(itx.opts, WithKey(itx.key), WithNonce(itx.nonce), WithFees(fees))...)
+	if err != nil {
+		return err
+	}
+	log.Printf("replacing stuck transaction %v (nonce %d) with %v. maxFeePerDataGas=%v", itx.last.Hash(), itx.nonce, tx.Hash(), fees.MaxFeePerDataGas)
+
+	err = s.builder.Send(ctx, tx)
+	switch {
+	case err == nil:
+	case isNonceTooLow(err):
+		// either one of the previous versions was included, and Wait picks up its receipt,
+		// or the nonce was used outside of this sender, which Wait detects from the node's nonce
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		itx.sentBlock = bn
+		itx.nonceUsed = true
+		return nil
+	case isUnderpriced(err):
+		// keep bumping from the new fees on the next attempt
+		log.Printf("replacement %v underpriced: %v", tx.Hash(), err)
+	default:
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	itx.last = tx
+	itx.sentBlock = bn
+	if err == nil {
+		itx.hashes = append(itx.hashes, tx.Hash())
+		s.inflight[tx.Hash()] = itx
+	}
+	return nil
+}
+
+func (s *Sender) bump(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+s.feeBump))
+	bumped.Div(bumped, big.NewInt(100))
+	if bumped.Cmp(fee) <= 0 {
+		bumped.Add(fee, common.Big1)
+	}
+	return bumped
+}
+
+func (s *Sender) reserve(ctx context.Context, addr common.Address) (uint64, error) {
+	s.mu.Lock()
+	acc, ok := s.accounts[addr]
+	synced := ok && acc.synced
+	s.mu.Unlock()
+	if !synced {
+		if err := s.resync(ctx, addr, 0); err != nil {
+			return 0, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc = s.accounts[addr]
+	if len(acc.released) > 0 {
+		nonce := acc.released[0]
+		acc.released = acc.released[1:]
+		return nonce, nil
+	}
+	nonce := acc.next
+	acc.next++
+	return nonce, nil
+}
+
+func (s *Sender) release(addr common.Address, nonce uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc := s.accounts[addr]
+	if nonce+1 == acc.next {
+		acc.next--
+		return
+	}
+	acc.released = append(acc.released, nonce)
+	sort.Slice(acc.released, func(i, j int) bool { return acc.released[i] < acc.released[j] })
+}
+
+// resync reconciles the next nonce of addr with the node. The next nonce is at least min.
+func (s *Sender) resync(ctx context.Context, addr common.Address, min uint64) error {
+	nonce, err := s.nonceAt(ctx, addr)
+	if err != nil {
+		return stepError(StepNonce, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, ok := s.accounts[addr]
+	if !ok {
+		acc = new(accountNonces)
+		s.accounts[addr] = acc
+	}
+	if nonce < min {
+		nonce = min
+	}
+	if !acc.synced || nonce > acc.next {
+		acc.next = nonce
+	}
+	acc.synced = true
+
+	// nonces below the node's nonce can't be reused
+	released := acc.released[:0]
+	for _, n := range acc.released {
+		if n >= nonce {
+			released = append(released, n)
+		}
+	}
+	acc.released = released
+	return nil
+}
+
+func (s *Sender) track(tx *types.Transaction, key *ecdsa.PrivateKey, opts []Option, nonce, bn uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inflight[tx.Hash()] = &inflightTx{
+		key:       key,
+		opts:      opts,
+		nonce:     nonce,
+		sentBlock: bn,
+		hashes:    []common.Hash{tx.Hash()},
+		last:      tx,
+	}
+}
+
+func (s *Sender) untrack(itx *inflightTx) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range itx.hashes {
+		delete(s.inflight, h)
+	}
+}
+
+// withOptions returns opts followed by extra, without modifying the backing array of opts
+func withOptions(opts []Option, extra ...Option) []Option {
+	all := make([]Option, 0, len(opts)+len(extra))
+	all = append(all, opts...)
+	return append(all, extra...)
+}
+
+// The following match the error messages of geth's txpool, which are only available as strings over JSON-RPC
+
+func isNonceTooLow(err error) bool {
+	return strings.Contains(err.Error(), "nonce too low")
+}
+
+func isAlreadyKnown(err error) bool {
+	return strings.Contains(err.Error(), "already known")
+}
+
+func isUnderpriced(err error) bool {
+	return strings.Contains(err.Error(), "replacement transaction underpriced")
+}
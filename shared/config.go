@@ -33,6 +33,15 @@ func BeaconChainConfigFilepath() string {
 	return fmt.Sprintf("%s/shared/generated-configs/custom_config_data/config.yaml", GetBaseDir())
 }
 
+// BootENRFilepath returns the bootnode ENR list of the devnet, written by the bootnode on startup
+func BootENRFilepath() string {
+	return fmt.Sprintf("%s/shared/generated-configs/custom_config_data/boot_enr.yaml", GetBaseDir())
+}
+
+func GenesisGeneratorValuesFilepath() string {
+	return fmt.Sprintf("%s/shared/genesis-generator-configs/values.env", GetBaseDir())
+}
+
 func UpdateChainConfig(config *params.ChainConfig) error {
 	file, err := json.MarshalIndent(config, "", " ")
 	if err != nil {
@@ -50,21 +59,56 @@ func GetBeaconFollowerMultiAddress() (string, error) {
 	return getMultiaddress("http://" + BeaconFollowerAPI)
 }
 
-func getMultiaddress(beaconAPI string) (string, error) {
+// GetBeaconPeerID returns the libp2p peer ID of the beacon node serving beaconAPI
+func GetBeaconPeerID(beaconAPI string) (string, error) {
+	data, err := getIdentity(beaconAPI)
+	if err != nil {
+		return "", err
+	}
+	if data.Data.PeerID == "" {
+		return "", errors.New("no peer id found")
+	}
+	return data.Data.PeerID, nil
+}
+
+// GetBeaconENR returns the ENR of the beacon node serving beaconAPI
+func GetBeaconENR(beaconAPI string) (string, error) {
+	data, err := getIdentity(beaconAPI)
+	if err != nil {
+		return "", err
+	}
+	if data.Data.ENR == "" {
+		return "", errors.New("no enr found")
+	}
+	return data.Data.ENR, nil
+}
+
+type identity struct {
+	Data struct {
+		PeerID       string   `json:"peer_id"`
+		ENR          string   `json:"enr"`
+		P2PAddresses []string `json:"p2p_addresses"`
+	} `json:"data"`
+}
+
+func getIdentity(beaconAPI string) (*identity, error) {
 	url := fmt.Sprintf("%s/eth/v1/node/identity", beaconAPI)
 	r, err := http.Get(url)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer r.Body.Close()
 
-	type Data struct {
-		Data struct {
-			P2PAddresses []string `json:"p2p_addresses"`
-		} `json:"data"`
-	}
-	var data Data
+	var data identity
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func getMultiaddress(beaconAPI string) (string, error) {
+	data, err := getIdentity(beaconAPI)
+	if err != nil {
 		return "", err
 	}
 	if len(data.Data.P2PAddresses) == 0 {
@@ -81,6 +125,9 @@ func getMultiaddress(beaconAPI string) (string, error) {
 
 var (
 	GethRPC           = "http://localhost:8545"
+	GethRPC2          = "http://localhost:8546"
+	GethEngineRPC     = "http://localhost:8551"
+	GethEngineRPC2    = "http://localhost:8552"
 	PrivateKey        = "45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8"
 	BeaconAPI         = "localhost:8000"
 	BeaconFollowerAPI = "localhost:8001"
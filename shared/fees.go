@@ -0,0 +1,65 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// DefaultFeeMultiplier is the safety margin applied to estimated data gas prices
+const DefaultFeeMultiplier = 2.0
+
+type BlobTxFees struct {
+	GasTipCap        *big.Int
+	GasFeeCap        *big.Int
+	MaxFeePerDataGas *big.Int
+}
+
+// EstimateBlobTxFees estimates the fees of a blob transaction that is to be included in one of the next blocks.
+// The estimated data gas price is scaled by multiplier to leave room for excess data gas to grow
+// before the transaction is included.
+func EstimateBlobTxFees(ctx context.Context, client *ethclient.Client, multiplier float64) (*BlobTxFees, error) {
+	if multiplier < 1 {
+		return nil, fmt.Errorf("fee multiplier must be at least 1, got %v", multiplier)
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: HeaderByNumber", err)
+	}
+	gasTipCap, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: SuggestGasTipCap", err)
+	}
+
+	gasFeeCap := new(big.Int).Set(gasTipCap)
+	if head.BaseFee != nil {
+		// leave room for the base fee to double, like geth's own fee suggestion
+		gasFeeCap.Add(gasFeeCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	}
+
+	return &BlobTxFees{
+		GasTipCap:        gasTipCap,
+		GasFeeCap:        gasFeeCap,
+		MaxFeePerDataGas: scaleFee(NextDataGasPrice(head), multiplier),
+	}, nil
+}
+
+// NextDataGasPrice returns the highest data gas price a transaction can be charged in the block after the child of parent.
+// It assumes that the child of parent is full of blobs, maximizing its excess data gas.
+func NextDataGasPrice(parent *types.Header) *big.Int {
+	excessDataGas := misc.CalcExcessDataGas(parent.ExcessDataGas, params.MaxBlobsPerBlock)
+	return misc.GetDataGasPrice(excessDataGas)
+}
+
+func scaleFee(fee *big.Int, multiplier float64) *big.Int {
+	scaled, _ := new(big.Float).Mul(new(big.Float).SetInt(fee), big.NewFloat(multiplier)).Int(nil)
+	if scaled.Cmp(fee) < 0 {
+		return new(big.Int).Set(fee)
+	}
+	return scaled
+}
@@ -0,0 +1,34 @@
+package shared
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/protolambda/go-kzg/eth"
+)
+
+// BlobFromBytes converts a flattened blob into a types.Blob
+func BlobFromBytes(data []byte) (types.Blob, error) {
+	var blob types.Blob
+	if len(data) != flatBlobSize {
+		return blob, errors.New("invalid blob size")
+	}
+	for i := range blob {
+		copy(blob[i][:], data[i*32:(i+1)*32])
+	}
+	return blob, nil
+}
+
+// BlobCommitment computes the KZG commitment and versioned hash of a flattened blob
+func BlobCommitment(data []byte) (types.KZGCommitment, common.Hash, error) {
+	blob, err := BlobFromBytes(data)
+	if err != nil {
+		return types.KZGCommitment{}, common.Hash{}, err
+	}
+	c, ok := eth.BlobToKZGCommitment(blob)
+	if !ok {
+		return types.KZGCommitment{}, common.Hash{}, errors.New("could not convert blob to commitment")
+	}
+	return types.KZGCommitment(c), common.Hash(eth.KZGToVersionedHash(c)), nil
+}
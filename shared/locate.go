@@ -0,0 +1,116 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// BlobLocation tells where the blobs of a transaction were included
+type BlobLocation struct {
+	TxHash          common.Hash
+	BlockHash       common.Hash
+	BlockNumber     uint64
+	Slot            uint64
+	VersionedHashes []common.Hash
+}
+
+// LocateTransactionBlobs follows the receipt of a blob transaction to the beacon block that includes its blobs
+func LocateTransactionBlobs(ctx context.Context, client *ethclient.Client, beaconAPI string, txHash common.Hash) (*BlobLocation, error) {
+	tx, _, err := client.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: TransactionByHash", err)
+	}
+	if len(tx.DataHashes()) == 0 {
+		return nil, fmt.Errorf("transaction %v carries no blobs", txHash)
+	}
+	receipt, err := client.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: TransactionReceipt", err)
+	}
+	header, err := client.HeaderByHash(ctx, receipt.BlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: HeaderByHash", err)
+	}
+	return locate(beaconAPI, tx, header)
+}
+
+// LocateVersionedHash searches the last searchBlocks execution blocks for the transaction carrying the blob with
+// the given versioned hash
+func LocateVersionedHash(ctx context.Context, client *ethclient.Client, beaconAPI string, versionedHash common.Hash, searchBlocks uint64) (*BlobLocation, error) {
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: BlockNumber", err)
+	}
+	for n := head; n+searchBlocks > head; n-- {
+		block, err := client.BlockByNumber(ctx, new(big.Int).SetUint64(n))
+		if err != nil {
+			return nil, fmt.Errorf("%w: BlockByNumber", err)
+		}
+		for _, tx := range block.Transactions() {
+			for _, h := range tx.DataHashes() {
+				if h == versionedHash {
+					return locate(beaconAPI, tx, block.Header())
+				}
+			}
+		}
+		if n == 0 {
+			break
+		}
+	}
+	return nil, fmt.Errorf("no transaction with versioned hash %v in the last %d blocks", versionedHash, searchBlocks)
+}
+
+func locate(beaconAPI string, tx *types.Transaction, header *types.Header) (*BlobLocation, error) {
+	slot, err := GetSlotForExecutionBlock(beaconAPI, header)
+	if err != nil {
+		return nil, err
+	}
+	return &BlobLocation{
+		TxHash:          tx.Hash(),
+		BlockHash:       header.Hash(),
+		BlockNumber:     header.Number.Uint64(),
+		Slot:            slot,
+		VersionedHashes: tx.DataHashes(),
+	}, nil
+}
+
+// FilterBlobs returns the flattened blobs matching versionedHashes, in the same order.
+// It fails if any of the versioned hashes is missing.
+func FilterBlobs(blobs [][]byte, versionedHashes []common.Hash) ([][]byte, error) {
+	found := make(map[common.Hash][]byte)
+	for _, blob := range blobs {
+		_, h, err := BlobCommitment(blob)
+		if err != nil {
+			return nil, err
+		}
+		found[h] = blob
+	}
+	var matched [][]byte
+	var missing []common.Hash
+	for _, h := range versionedHashes {
+		blob, ok := found[h]
+		if !ok {
+			missing = append(missing, h)
+			continue
+		}
+		matched = append(matched, blob)
+	}
+	if len(missing) != 0 {
+		return matched, &MissingBlobsError{VersionedHashes: missing}
+	}
+	return matched, nil
+}
+
+// MissingBlobsError lists the versioned hashes that couldn't be matched by FilterBlobs
+type MissingBlobsError struct {
+	VersionedHashes []common.Hash
+}
+
+func (e *MissingBlobsError) Error() string {
+	return fmt.Sprintf("%d blobs not found: %v", len(e.VersionedHashes), e.VersionedHashes)
+}
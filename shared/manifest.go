@@ -0,0 +1,51 @@
+package shared
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// MaxBlobsPerTx is the maximum number of blobs accepted in a single blob transaction
+const MaxBlobsPerTx = params.MaxBlobsPerBlock
+
+// Manifest describes a payload that was uploaded across several blob transactions.
+// The payload is reassembled by decoding the blobs in the order of Blobs.
+type Manifest struct {
+	// Length is the total payload length in bytes
+	Length       uint64                `json:"length"`
+	Blobs        []common.Hash         `json:"blobs"`
+	Transactions []ManifestTransaction `json:"transactions"`
+}
+
+type ManifestTransaction struct {
+	Hash            common.Hash   `json:"hash"`
+	Nonce           uint64        `json:"nonce"`
+	VersionedHashes []common.Hash `json:"versionedHashes"`
+}
+
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if len(m.Blobs) == 0 {
+		return nil, errors.New("manifest lists no blobs")
+	}
+	return &m, nil
+}
+
+func WriteManifest(path string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,143 @@
+package p2pclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/prysmaticlabs/prysm/v3/api/client/beacon"
+	"github.com/prysmaticlabs/prysm/v3/beacon-chain/core/signing"
+	types "github.com/prysmaticlabs/prysm/v3/consensus-types/primitives"
+)
+
+// Fork is an entry of the fork schedule
+type Fork struct {
+	Epoch   uint64
+	Version [4]byte
+}
+
+// ChainConfig is what the client needs to know about the chain to compute fork digests
+type ChainConfig struct {
+	GenesisValidatorsRoot [32]byte
+	GenesisTime           uint64
+	SecondsPerSlot        uint64
+	SlotsPerEpoch         uint64
+	// Forks lists every fork of the chain, including genesis, in any order
+	Forks []Fork
+}
+
+// ForkDigest returns the fork digest in effect at epoch
+func (c *ChainConfig) ForkDigest(epoch uint64) ([4]byte, error) {
+	forks := make([]Fork, len(c.Forks))
+	copy(forks, c.Forks)
+	sort.SliceStable(forks, func(i, j int) bool { return forks[i].Epoch < forks[j].Epoch })
+
+	var version *[4]byte
+	for i := range forks {
+		if forks[i].Epoch > epoch {
+			break
+		}
+		version = &forks[i].Version
+	}
+	if version == nil {
+		return [4]byte{}, fmt.Errorf("no fork scheduled at epoch %d", epoch)
+	}
+	return signing.ComputeForkDigest(version[:], c.GenesisValidatorsRoot[:])
+}
+
+// SlotDigest returns the fork digest in effect at slot
+func (c *ChainConfig) SlotDigest(slot types.Slot) ([4]byte, error) {
+	return c.ForkDigest(uint64(slot) / c.SlotsPerEpoch)
+}
+
+// CurrentEpoch returns the epoch of the wall clock
+func (c *ChainConfig) CurrentEpoch() uint64 {
+	now := uint64(time.Now().Unix())
+	if now < c.GenesisTime {
+		return 0
+	}
+	return (now - c.GenesisTime) / c.SecondsPerSlot / c.SlotsPerEpoch
+}
+
+// FetchGenesis returns the genesis validators root and genesis time of the chain followed by the beacon node
+func FetchGenesis(ctx context.Context, client *beacon.Client) ([32]byte, uint64, error) {
+	var genesis struct {
+		Data struct {
+			GenesisTime           string        `json:"genesis_time"`
+			GenesisValidatorsRoot hexutil.Bytes `json:"genesis_validators_root"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, client, "/eth/v1/beacon/genesis", &genesis); err != nil {
+		return [32]byte{}, 0, err
+	}
+	var root [32]byte
+	if len(genesis.Data.GenesisValidatorsRoot) != len(root) {
+		return root, 0, fmt.Errorf("invalid genesis validators root %v", genesis.Data.GenesisValidatorsRoot)
+	}
+	copy(root[:], genesis.Data.GenesisValidatorsRoot)
+	genesisTime, err := strconv.ParseUint(genesis.Data.GenesisTime, 10, 64)
+	if err != nil {
+		return root, 0, fmt.Errorf("invalid genesis time %q: %w", genesis.Data.GenesisTime, err)
+	}
+	return root, genesisTime, nil
+}
+
+// FetchChainConfig builds the ChainConfig of the chain followed by the beacon node
+func FetchChainConfig(ctx context.Context, client *beacon.Client) (*ChainConfig, error) {
+	root, genesisTime, err := FetchGenesis(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	var spec struct {
+		Data struct {
+			SecondsPerSlot string `json:"SECONDS_PER_SLOT"`
+			SlotsPerEpoch  string `json:"SLOTS_PER_EPOCH"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, client, "/eth/v1/config/spec", &spec); err != nil {
+		return nil, err
+	}
+	secondsPerSlot, err := strconv.ParseUint(spec.Data.SecondsPerSlot, 10, 64)
+	if err != nil || secondsPerSlot == 0 {
+		return nil, fmt.Errorf("invalid SECONDS_PER_SLOT %q", spec.Data.SecondsPerSlot)
+	}
+	slotsPerEpoch, err := strconv.ParseUint(spec.Data.SlotsPerEpoch, 10, 64)
+	if err != nil || slotsPerEpoch == 0 {
+		return nil, fmt.Errorf("invalid SLOTS_PER_EPOCH %q", spec.Data.SlotsPerEpoch)
+	}
+	schedule, err := client.GetForkSchedule(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: GetForkSchedule", err)
+	}
+	cfg := &ChainConfig{
+		GenesisValidatorsRoot: root,
+		GenesisTime:           genesisTime,
+		SecondsPerSlot:        secondsPerSlot,
+		SlotsPerEpoch:         slotsPerEpoch,
+	}
+	for _, f := range schedule {
+		cfg.Forks = append(cfg.Forks, Fork{Epoch: uint64(f.Epoch), Version: f.Version})
+	}
+	return cfg, nil
+}
+
+func getJSON(ctx context.Context, client *beacon.Client, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.NodeURL()+path, nil)
+	if err != nil {
+		return err
+	}
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", path, r.Status)
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
@@ -0,0 +1,101 @@
+// Package p2pclient talks to beacon nodes over their libp2p req/resp protocols.
+package p2pclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	"github.com/prysmaticlabs/prysm/v3/beacon-chain/p2p"
+	"github.com/prysmaticlabs/prysm/v3/beacon-chain/p2p/encoder"
+	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
+)
+
+// Client owns a libp2p host that looks enough like a beacon node for clients to serve its requests.
+// A single Client can be connected to any number of peers and should be reused for the whole run.
+type Client struct {
+	h        host.Host
+	encoding encoder.NetworkEncoding
+	chain    *ChainConfig
+
+	mu       sync.Mutex
+	statuses map[peer.ID]*ethpb.Status
+	gossip   *pubsub.PubSub
+	// topics are joined once and shared by every subscription
+	topics map[string]*pubsub.Topic
+}
+
+// New creates a Client for the chain described by chain. Without a chain config, the Client skips the Status
+// handshake and doesn't check the context bytes of responses, which some clients won't put up with for long.
+func New(chain *ChainConfig) (*Client, error) {
+	// Hack to ensure that we are able to download blob chunks with larger chunk sizes (which is 10 MiB post-bellatrix)
+	encoder.MaxChunkSize = 10 << 20
+	encoder.MaxGossipSize = maxGossipSize
+
+	h, err := libp2p.New(libp2p.Transport(tcp.NewTCPTransport))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to create libp2p host", err)
+	}
+	c := &Client{
+		h:        h,
+		encoding: encoder.SszNetworkEncoder{},
+		chain:    chain,
+		statuses: make(map[peer.ID]*ethpb.Status),
+	}
+
+	h.RemoveStreamHandler(identify.IDDelta)
+	// setup enough handlers so lighthouse thinks it's dealing with a beacon peer
+	c.setHandler(p2p.RPCPingTopicV1, pingHandler)
+	c.setHandler(p2p.RPCGoodByeTopicV1, pingHandler)
+	c.setHandler(p2p.RPCMetaDataTopicV1, pingHandler)
+	c.setHandler(p2p.RPCMetaDataTopicV2, pingHandler)
+	if chain != nil {
+		c.setHandler(p2p.RPCStatusTopicV1, c.statusHandler)
+	}
+
+	c.setHandler(p2p.RPCBlocksByRangeTopicV1, nilHandler)
+	c.setHandler(p2p.RPCBlocksByRangeTopicV2, nilHandler)
+	c.setHandler(p2p.RPCBlocksByRootTopicV1, nilHandler)
+	c.setHandler(p2p.RPCBlocksByRootTopicV2, nilHandler)
+	c.setHandler(p2p.RPCBlobsSidecarsByRangeTopicV1, nilHandler)
+	c.setHandler(p2p.RPCBeaconBlockAndBlobsSidecarByRootV1, nilHandler)
+	return c, nil
+}
+
+// Host returns the underlying libp2p host
+func (c *Client) Host() host.Host {
+	return c.h
+}
+
+// Connect connects to the peer at addr, a multiaddr ending with /p2p/<peer id>.
+// New connections start with a Status handshake, which fails if the peer is on another fork.
+func (c *Client) Connect(ctx context.Context, addr string) (peer.ID, error) {
+	addrInfo, err := peer.AddrInfoFromString(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid addr %s: %w", addr, err)
+	}
+	if c.h.Network().Connectedness(addrInfo.ID) == network.Connected {
+		return addrInfo.ID, nil
+	}
+	if err := c.h.Connect(ctx, *addrInfo); err != nil {
+		return "", fmt.Errorf("%w: libp2p host connect", err)
+	}
+	if c.chain != nil {
+		if _, err := c.exchangeStatus(ctx, addrInfo.ID); err != nil {
+			_ = c.h.Network().ClosePeer(addrInfo.ID)
+			return "", err
+		}
+	}
+	return addrInfo.ID, nil
+}
+
+func (c *Client) Close() error {
+	return c.h.Close()
+}
@@ -0,0 +1,160 @@
+package p2pclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+
+	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Discover walks the discv5 DHT from bootnodes until it finds limit beacon nodes accepted by filter, or ctx is done.
+// Only nodes advertising a TCP endpoint and a fork digest are considered. filter may be nil.
+func Discover(ctx context.Context, bootnodes []*enode.Node, filter func(*enode.Node) bool, limit int) ([]*enode.Node, error) {
+	it, err := discoverNodes(ctx, bootnodes, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var nodes []*enode.Node
+	for len(nodes) < limit && it.Next() {
+		nodes = append(nodes, it.Node())
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no beacon nodes found through %d bootnodes", len(bootnodes))
+	}
+	return nodes, nil
+}
+
+// discoverNodes starts a discv5 listener and returns an iterator over the distinct beacon nodes it finds that are
+// accepted by filter. Next returns false once ctx is done. Closing the iterator stops the listener.
+func discoverNodes(ctx context.Context, bootnodes []*enode.Node, filter func(*enode.Node) bool) (enode.Iterator, error) {
+	key, err := gethcrypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	db, err := enode.OpenDB("")
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%w: discv5 listen", err)
+	}
+	ln := enode.NewLocalNode(db, key)
+	disc, err := discover.ListenV5(conn, ln, discover.Config{PrivateKey: key, Bootnodes: bootnodes})
+	if err != nil {
+		_ = conn.Close()
+		db.Close()
+		return nil, fmt.Errorf("%w: discv5 listen", err)
+	}
+
+	isBootnode := make(map[enode.ID]bool)
+	for _, b := range bootnodes {
+		isBootnode[b.ID()] = true
+	}
+	seen := make(map[enode.ID]bool)
+	it := enode.Filter(disc.RandomNodes(), func(node *enode.Node) bool {
+		if isBootnode[node.ID()] || seen[node.ID()] || node.TCP() == 0 {
+			return false
+		}
+		if _, ok := NodeForkDigest(node); !ok {
+			return false
+		}
+		if filter != nil && !filter(node) {
+			return false
+		}
+		seen[node.ID()] = true
+		return true
+	})
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	// the iterator blocks until it finds a node, so it has to be closed for Next to return once ctx is done
+	go func() {
+		<-ctx.Done()
+		it.Close()
+		disc.Close()
+		db.Close()
+		close(done)
+	}()
+	return &discoveryIterator{Iterator: it, cancel: cancel, done: done}, nil
+}
+
+// discoveryIterator stops the discv5 listener of its nodes when closed
+type discoveryIterator struct {
+	enode.Iterator
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (it *discoveryIterator) Close() {
+	it.cancel()
+	<-it.done
+}
+
+// ForkDigestFilter accepts the nodes on the current fork of chain
+func ForkDigestFilter(chain *ChainConfig) (func(*enode.Node) bool, error) {
+	expected, err := chain.ForkDigest(chain.CurrentEpoch())
+	if err != nil {
+		return nil, err
+	}
+	return func(node *enode.Node) bool {
+		digest, _ := NodeForkDigest(node)
+		return digest == expected
+	}, nil
+}
+
+// PeerIDFilter accepts the node with the given libp2p peer ID
+func PeerIDFilter(pid peer.ID) func(*enode.Node) bool {
+	return func(node *enode.Node) bool {
+		id, err := NodePeerID(node)
+		return err == nil && id == pid
+	}
+}
+
+// ConnectDiscovered connects to n beacon nodes found through discv5 from bootnodes, skipping the ones on another fork
+// or refusing the connection. It returns the peers connected so far once ctx is done, failing if there are none.
+func (c *Client) ConnectDiscovered(ctx context.Context, bootnodes []*enode.Node, n int) ([]peer.ID, error) {
+	var onFork func(*enode.Node) bool
+	if c.chain != nil {
+		var err error
+		if onFork, err = ForkDigestFilter(c.chain); err != nil {
+			return nil, err
+		}
+	}
+	it, err := discoverNodes(ctx, bootnodes, onFork)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var pids []peer.ID
+	for len(pids) < n && it.Next() {
+		node := it.Node()
+		pid, err := c.ConnectNode(ctx, node)
+		if err != nil {
+			log.Printf("Skipping discovered peer %v: %v", node.ID(), err)
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("unable to connect to a beacon node discovered through %d bootnodes", len(bootnodes))
+	}
+	return pids, nil
+}
+
+// ConnectNode connects to the beacon node described by an ENR
+func (c *Client) ConnectNode(ctx context.Context, node *enode.Node) (peer.ID, error) {
+	addrInfo, err := NodeAddrInfo(node)
+	if err != nil {
+		return "", err
+	}
+	return c.Connect(ctx, fmt.Sprintf("%s/p2p/%s", addrInfo.Addrs[0], addrInfo.ID))
+}
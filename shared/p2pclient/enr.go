@@ -0,0 +1,97 @@
+package p2pclient
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	gethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"gopkg.in/yaml.v2"
+)
+
+// eth2ENRKey is the ENR entry holding the SSZ-encoded ENRForkID of a beacon node
+const eth2ENRKey = "eth2"
+
+// ParseENR parses an "enr:" record
+func ParseENR(s string) (*enode.Node, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "enr:") {
+		return nil, fmt.Errorf("invalid ENR %q: missing enr: prefix", s)
+	}
+	node, err := enode.Parse(enode.ValidSchemes, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ENR %q: %w", s, err)
+	}
+	return node, nil
+}
+
+// ReadENRFile reads a list of ENRs in the format of the devnet's boot_enr.yaml
+func ReadENRFile(path string) ([]*enode.Node, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []string
+	if err := yaml.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("invalid ENR file %s: %w", path, err)
+	}
+	var nodes []*enode.Node
+	for _, r := range records {
+		node, err := ParseENR(r)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// NodePeerID returns the libp2p peer ID derived from the public key of node
+func NodePeerID(node *enode.Node) (peer.ID, error) {
+	pubkey := node.Pubkey()
+	if pubkey == nil {
+		return "", errors.New("ENR has no secp256k1 public key")
+	}
+	key, err := crypto.UnmarshalSecp256k1PublicKey(gethcrypto.CompressPubkey(pubkey))
+	if err != nil {
+		return "", err
+	}
+	return peer.IDFromPublicKey(key)
+}
+
+// NodeAddrInfo returns the libp2p address of node, which must advertise an IP and a TCP port
+func NodeAddrInfo(node *enode.Node) (*peer.AddrInfo, error) {
+	if node.IP() == nil || node.TCP() == 0 {
+		return nil, fmt.Errorf("ENR of %v has no TCP endpoint", node.ID())
+	}
+	id, err := NodePeerID(node)
+	if err != nil {
+		return nil, err
+	}
+	proto := "ip4"
+	if node.IP().To4() == nil {
+		proto = "ip6"
+	}
+	addr, err := ma.NewMultiaddr(fmt.Sprintf("/%s/%s/tcp/%d", proto, node.IP(), node.TCP()))
+	if err != nil {
+		return nil, err
+	}
+	return &peer.AddrInfo{ID: id, Addrs: []ma.Multiaddr{addr}}, nil
+}
+
+// NodeForkDigest returns the current fork digest advertised by a beacon node, if any
+func NodeForkDigest(node *enode.Node) ([4]byte, bool) {
+	var forkID []byte
+	if err := node.Load(enr.WithEntry(eth2ENRKey, &forkID)); err != nil || len(forkID) < 4 {
+		return [4]byte{}, false
+	}
+	var digest [4]byte
+	copy(digest[:], forkID)
+	return digest, true
+}
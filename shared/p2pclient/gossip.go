@@ -0,0 +1,220 @@
+package p2pclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/snappy"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pubsubpb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/prysmaticlabs/prysm/v3/beacon-chain/p2p"
+	types "github.com/prysmaticlabs/prysm/v3/consensus-types/primitives"
+	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
+)
+
+// maxGossipSize is GOSSIP_MAX_SIZE_BELLATRIX
+const maxGossipSize = 10 << 20
+
+var (
+	messageDomainInvalidSnappy = []byte{0x00, 0x00, 0x00, 0x00}
+	messageDomainValidSnappy   = []byte{0x01, 0x00, 0x00, 0x00}
+)
+
+// GossipBlock is a beacon block and its blobs sidecar received on the coupled gossip topic
+type GossipBlock struct {
+	Slot          types.Slot
+	ProposerIndex types.ValidatorIndex
+	BlockRoot     [32]byte
+	Blobs         int
+	From          peer.ID
+	ReceivedAt    time.Time
+	// Latency is the time between the start of the slot and the arrival of the message
+	Latency time.Duration
+	Message *ethpb.SignedBeaconBlockAndBlobsSidecar
+}
+
+// Gossip returns the gossipsub router of the client, starting it on first use. It requires a chain config.
+func (c *Client) Gossip() (*pubsub.PubSub, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gossip != nil {
+		return c.gossip, nil
+	}
+	if c.chain == nil {
+		return nil, errors.New("gossip requires a chain config")
+	}
+	ps, err := pubsub.NewGossipSub(context.Background(), c.h,
+		pubsub.WithMessageSignaturePolicy(pubsub.StrictNoSign),
+		pubsub.WithNoAuthor(),
+		pubsub.WithMessageIdFn(messageID),
+		pubsub.WithMaxMessageSize(maxGossipSize),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to start gossipsub", err)
+	}
+	c.gossip = ps
+	return ps, nil
+}
+
+// joinTopic returns the gossip topic with the given name, joining it on first use
+func (c *Client) joinTopic(name string) (*pubsub.Topic, error) {
+	ps, err := c.Gossip()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if topic, ok := c.topics[name]; ok {
+		return topic, nil
+	}
+	topic, err := ps.Join(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to join topic", err)
+	}
+	if c.topics == nil {
+		c.topics = make(map[string]*pubsub.Topic)
+	}
+	c.topics[name] = topic
+	return topic, nil
+}
+
+// BlockAndBlobsTopic returns the beacon_block_and_blobs_sidecar gossip topic of a fork digest
+func (c *Client) BlockAndBlobsTopic(digest [4]byte) string {
+	return fmt.Sprintf(p2p.BlockAndBlobsSubnetTopicFormat, digest) + c.encoding.ProtocolSuffix()
+}
+
+// WatchBlocksAndBlobs subscribes to the beacon_block_and_blobs_sidecar topics of the current and upcoming forks, and
+// calls fn with every message received until ctx is done. Messages that fail to decode are reported through onError.
+func (c *Client) WatchBlocksAndBlobs(ctx context.Context, fn func(*GossipBlock), onError func(error)) error {
+	if _, err := c.Gossip(); err != nil {
+		return err
+	}
+	digests, err := c.upcomingDigests()
+	if err != nil {
+		return err
+	}
+	msgs := make(chan *pubsub.Message)
+	for _, digest := range digests {
+		topic, err := c.joinTopic(c.BlockAndBlobsTopic(digest))
+		if err != nil {
+			return err
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			return fmt.Errorf("%w: failed to subscribe to topic", err)
+		}
+		defer sub.Cancel()
+		go func() {
+			for {
+				msg, err := sub.Next(ctx)
+				if err != nil {
+					return
+				}
+				select {
+				case msgs <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-msgs:
+			block, err := c.decodeGossipBlock(msg, time.Now())
+			if err != nil {
+				onError(err)
+				continue
+			}
+			fn(block)
+		}
+	}
+}
+
+func (c *Client) decodeGossipBlock(msg *pubsub.Message, receivedAt time.Time) (*GossipBlock, error) {
+	m := new(ethpb.SignedBeaconBlockAndBlobsSidecar)
+	if err := c.encoding.DecodeGossip(msg.Data, m); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode gossip message from %s", err, msg.ReceivedFrom)
+	}
+	block := m.GetBeaconBlock().GetBlock()
+	root, err := block.HashTreeRoot()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to compute block root", err)
+	}
+	slot := block.GetSlot()
+	slotStart := time.Unix(int64(c.chain.GenesisTime+uint64(slot)*c.chain.SecondsPerSlot), 0)
+	return &GossipBlock{
+		Slot:          slot,
+		ProposerIndex: block.GetProposerIndex(),
+		BlockRoot:     root,
+		Blobs:         len(m.GetBlobsSidecar().GetBlobs()),
+		From:          msg.ReceivedFrom,
+		ReceivedAt:    receivedAt,
+		Latency:       receivedAt.Sub(slotStart),
+		Message:       m,
+	}, nil
+}
+
+// upcomingDigests returns the fork digests of the current fork and of every scheduled fork after it
+func (c *Client) upcomingDigests() ([][4]byte, error) {
+	current := c.chain.CurrentEpoch()
+	epochs := []uint64{current}
+	for _, f := range c.chain.Forks {
+		if f.Epoch > current {
+			epochs = append(epochs, f.Epoch)
+		}
+	}
+	seen := make(map[[4]byte]bool)
+	var digests [][4]byte
+	for _, epoch := range epochs {
+		digest, err := c.chain.ForkDigest(epoch)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[digest] {
+			seen[digest] = true
+			digests = append(digests, digest)
+		}
+	}
+	return digests, nil
+}
+
+// messageID computes the post-altair message-id of the consensus specs
+func messageID(pmsg *pubsubpb.Message) string {
+	topic := pmsg.GetTopic()
+	var topicLen [8]byte
+	binary.LittleEndian.PutUint64(topicLen[:], uint64(len(topic)))
+
+	h := sha256.New()
+	if data, err := decodeSnappy(pmsg.Data); err == nil {
+		h.Write(messageDomainValidSnappy)
+		h.Write(topicLen[:])
+		h.Write([]byte(topic))
+		h.Write(data)
+	} else {
+		h.Write(messageDomainInvalidSnappy)
+		h.Write(topicLen[:])
+		h.Write([]byte(topic))
+		h.Write(pmsg.Data)
+	}
+	return string(h.Sum(nil)[:20])
+}
+
+func decodeSnappy(b []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(b)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxGossipSize {
+		return nil, fmt.Errorf("gossip message of %d bytes is too large", n)
+	}
+	return snappy.Decode(nil, b)
+}
@@ -0,0 +1,120 @@
+package p2pclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	types "github.com/prysmaticlabs/prysm/v3/consensus-types/primitives"
+	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
+)
+
+// MaxRequestBlobsSidecars is MAX_REQUEST_BLOBS_SIDECARS, the largest number of slots a by-range request may cover
+const MaxRequestBlobsSidecars = 128
+
+const (
+	// maxRateLimitRetries bounds the retries of a single page refused because of rate limits
+	maxRateLimitRetries = 6
+	initialBackoff      = time.Second
+	maxBackoff          = 30 * time.Second
+)
+
+// ErrInvalidRange is returned when a peer answers a by-range request with sidecars out of order or outside the range
+var ErrInvalidRange = errors.New("invalid by-range response")
+
+// pagedSidecarsByRange requests the sidecars of [start, start+count) from pid, pageSize slots at a time.
+// A page that comes back short is resumed after the last slot received, until the peer has nothing more to send:
+// a peer may stop early, and sidecars of empty slots are legitimately missing, so only another request can tell.
+func (c *Client) pagedSidecarsByRange(ctx context.Context, pid peer.ID, start, count, pageSize uint64) ([]*ethpb.BlobsSidecar, error) {
+	var sidecars []*ethpb.BlobsSidecar
+	end := start + count
+	// truncatedEnd is the end of the previous page when resuming after a short response
+	var truncatedEnd uint64
+	for next := start; next < end; {
+		n := end - next
+		if n > pageSize {
+			n = pageSize
+		}
+		page, err := c.sidecarsByRangeWithBackoff(ctx, pid, next, n)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkRange(page, next, n); err != nil {
+			return nil, err
+		}
+		sidecars = append(sidecars, page...)
+		if len(page) != 0 && uint64(page[0].BeaconBlockSlot) < truncatedEnd {
+			log.Printf("%s truncated its response at slot %d, resumed", pid, page[0].BeaconBlockSlot)
+		}
+
+		pageEnd := next + n
+		var short bool
+		next, short = resumeSlot(page, pageEnd)
+		truncatedEnd = 0
+		if short {
+			truncatedEnd = pageEnd
+		}
+	}
+	return sidecars, nil
+}
+
+// resumeSlot returns the slot to request next after page, the response to a request ending before pageEnd,
+// and whether page came back short
+func resumeSlot(page []*ethpb.BlobsSidecar, pageEnd uint64) (next uint64, short bool) {
+	if len(page) == 0 {
+		return pageEnd, false
+	}
+	if last := uint64(page[len(page)-1].BeaconBlockSlot); last+1 < pageEnd {
+		// the peer may have truncated its response, resume from the last slot received
+		return last + 1, true
+	}
+	return pageEnd, false
+}
+
+// checkRange verifies that the sidecars of a response are in [start, start+count), in increasing slot order
+func checkRange(sidecars []*ethpb.BlobsSidecar, start, count uint64) error {
+	var prev types.Slot
+	for i, sc := range sidecars {
+		slot := sc.BeaconBlockSlot
+		if uint64(slot) < start || uint64(slot) >= start+count {
+			return fmt.Errorf("%w: slot %d is outside of the requested range [%d, %d)", ErrInvalidRange, slot, start, start+count)
+		}
+		if i > 0 && slot == prev {
+			return fmt.Errorf("%w: duplicate sidecar for slot %d", ErrInvalidRange, slot)
+		}
+		if i > 0 && slot < prev {
+			return fmt.Errorf("%w: slot %d after slot %d", ErrInvalidRange, slot, prev)
+		}
+		prev = slot
+	}
+	return nil
+}
+
+// sidecarsByRangeWithBackoff sends a single by-range request, retrying with exponential backoff while the peer
+// refuses it because of its rate limits
+func (c *Client) sidecarsByRangeWithBackoff(ctx context.Context, pid peer.ID, start, count uint64) ([]*ethpb.BlobsSidecar, error) {
+	req := &ethpb.BlobsSidecarsByRangeRequest{
+		StartSlot: types.Slot(start),
+		Count:     count,
+	}
+	backoff := initialBackoff
+	for retry := 0; ; retry++ {
+		sidecars, err := c.BlobsSidecarsByRange(ctx, pid, req)
+		var respErr *ResponseError
+		if err == nil || !errors.As(err, &respErr) || !respErr.RateLimited() || retry == maxRateLimitRetries {
+			return sidecars, err
+		}
+		log.Printf("Rate limited by %s, retrying slots [%d, %d) in %v", pid, start, start+count, backoff)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
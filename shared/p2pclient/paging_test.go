@@ -0,0 +1,73 @@
+package p2pclient
+
+import (
+	"errors"
+	"testing"
+
+	types "github.com/prysmaticlabs/prysm/v3/consensus-types/primitives"
+	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
+)
+
+// sidecarsAt returns empty sidecars of the given slots
+func sidecarsAt(slots ...uint64) []*ethpb.BlobsSidecar {
+	sidecars := make([]*ethpb.BlobsSidecar, len(slots))
+	for i, slot := range slots {
+		sidecars[i] = &ethpb.BlobsSidecar{BeaconBlockSlot: types.Slot(slot)}
+	}
+	return sidecars
+}
+
+func TestCheckRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		sidecars []*ethpb.BlobsSidecar
+		start    uint64
+		count    uint64
+		valid    bool
+	}{
+		{"empty", nil, 10, 5, true},
+		{"in order", sidecarsAt(10, 11, 12, 13, 14), 10, 5, true},
+		{"with gaps", sidecarsAt(11, 14), 10, 5, true},
+		{"duplicate slot", sidecarsAt(10, 11, 11, 12), 10, 5, false},
+		{"decreasing slot", sidecarsAt(10, 12, 11), 10, 5, false},
+		{"slot below start", sidecarsAt(9, 10), 10, 5, false},
+		{"slot at start+count", sidecarsAt(13, 15), 10, 5, false},
+		{"last slot of the range", sidecarsAt(14), 10, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRange(tt.sidecars, tt.start, tt.count)
+			if tt.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidRange) {
+				t.Fatalf("got error %v, want %v", err, ErrInvalidRange)
+			}
+		})
+	}
+}
+
+func TestResumeSlot(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    []*ethpb.BlobsSidecar
+		pageEnd uint64
+		next    uint64
+		short   bool
+	}{
+		{"empty page advances to the page end", nil, 20, 20, false},
+		{"full page", sidecarsAt(16, 17, 18, 19), 20, 20, false},
+		{"gaps before the last slot", sidecarsAt(16, 19), 20, 20, false},
+		{"short page resumes after the last slot", sidecarsAt(16, 17), 20, 18, true},
+		{"short page of one sidecar", sidecarsAt(16), 20, 17, true},
+		{"last slot but one", sidecarsAt(18), 20, 19, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, short := resumeSlot(tt.page, tt.pageEnd)
+			if next != tt.next || short != tt.short {
+				t.Errorf("got next %d short %v, want next %d short %v", next, short, tt.next, tt.short)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package p2pclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	ssz "github.com/prysmaticlabs/fastssz"
+	"github.com/prysmaticlabs/prysm/v3/beacon-chain/p2p"
+	p2ptypes "github.com/prysmaticlabs/prysm/v3/beacon-chain/p2p/types"
+	"github.com/prysmaticlabs/prysm/v3/beacon-chain/sync"
+	types "github.com/prysmaticlabs/prysm/v3/consensus-types/primitives"
+	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
+)
+
+const responseCodeSuccess = byte(0x00)
+
+// responseCodeRateLimited is sent by lighthouse when a request exceeds its rate limits. The specs leave codes above 127
+// to clients.
+const responseCodeRateLimited = byte(139)
+
+// ResponseError is an error response sent by a peer
+type ResponseError struct {
+	Code    uint8
+	Message string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("error response %d: %s", e.Code, e.Message)
+}
+
+// RateLimited tells whether the peer refused the request because of its rate limits
+func (e *ResponseError) RateLimited() bool {
+	return e.Code == responseCodeRateLimited || strings.Contains(strings.ToLower(e.Message), "rate limit")
+}
+
+// chunkTimeout bounds the time to wait for each response chunk after the first one
+const chunkTimeout = 10 * time.Second
+
+// BlobsSidecarsByRange requests the blobs sidecars of a range of slots from pid
+func (c *Client) BlobsSidecarsByRange(ctx context.Context, pid peer.ID, req *ethpb.BlobsSidecarsByRangeRequest) ([]*ethpb.BlobsSidecar, error) {
+	var sidecars []*ethpb.BlobsSidecar
+	err := c.request(ctx, pid, p2p.RPCBlobsSidecarsByRangeTopicV1, req, func() responseChunk {
+		sidecar := new(ethpb.BlobsSidecar)
+		sidecars = append(sidecars, sidecar)
+		return responseChunk{msg: sidecar, slot: sidecar.GetBeaconBlockSlot}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sidecars, nil
+}
+
+// BeaconBlockAndBlobsSidecarByRoot requests the blocks with the given roots along with their blobs sidecars from pid.
+// Unlike by-range requests, the blocks don't need to be canonical.
+func (c *Client) BeaconBlockAndBlobsSidecarByRoot(ctx context.Context, pid peer.ID, roots [][32]byte) ([]*ethpb.SignedBeaconBlockAndBlobsSidecar, error) {
+	var resp []*ethpb.SignedBeaconBlockAndBlobsSidecar
+	req := p2ptypes.BeaconBlockByRootsReq(roots)
+	err := c.request(ctx, pid, p2p.RPCBeaconBlockAndBlobsSidecarByRootV1, &req, func() responseChunk {
+		r := new(ethpb.SignedBeaconBlockAndBlobsSidecar)
+		resp = append(resp, r)
+		return responseChunk{msg: r, slot: func() types.Slot { return r.GetBeaconBlock().GetBlock().GetSlot() }}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// responseChunk is the message a response chunk is decoded into
+type responseChunk struct {
+	msg ssz.Unmarshaler
+	// slot returns the slot of the decoded message, which determines the fork digest expected in the context bytes.
+	// It is nil for messages sent without context bytes.
+	slot func() types.Slot
+}
+
+// request sends req on the topic and reads response chunks until the peer closes the stream.
+// next returns the message the next chunk is decoded into.
+func (c *Client) request(ctx context.Context, pid peer.ID, baseTopic string, req ssz.Marshaler, next func() responseChunk) error {
+	topic := fmt.Sprintf("%s%s", baseTopic, c.encoding.ProtocolSuffix())
+
+	stream, err := c.h.NewStream(ctx, pid, protocol.ID(topic))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stream.Close()
+	}()
+
+	if _, err := c.encoding.EncodeWithMaxLength(stream, req); err != nil {
+		_ = stream.Reset()
+		return err
+	}
+
+	if err := stream.CloseWrite(); err != nil {
+		_ = stream.Reset()
+		return err
+	}
+
+	for chunk := 0; ; chunk++ {
+		err := c.readChunk(stream, chunk == 0, next)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readChunk reads a response chunk. The message is only allocated through next once a chunk is known to follow.
+func (c *Client) readChunk(stream network.Stream, isFirstChunk bool, next func() responseChunk) error {
+	var (
+		code   uint8
+		errMsg string
+		err    error
+	)
+	if isFirstChunk {
+		code, errMsg, err = sync.ReadStatusCode(stream, c.encoding)
+	} else {
+		sync.SetStreamReadDeadline(stream, chunkTimeout)
+		code, errMsg, err = c.readStatusCodeNoDeadline(stream)
+	}
+	if err != nil {
+		return err
+	}
+	if code != responseCodeSuccess {
+		return &ResponseError{Code: code, Message: errMsg}
+	}
+	chunk := next()
+	if chunk.slot == nil {
+		return c.encoding.DecodeWithMaxLength(stream, chunk.msg)
+	}
+	var contextBytes [4]byte
+	if _, err := io.ReadFull(stream, contextBytes[:]); err != nil {
+		return err
+	}
+	if err := c.encoding.DecodeWithMaxLength(stream, chunk.msg); err != nil {
+		return err
+	}
+	return c.checkContext(contextBytes, chunk.slot())
+}
+
+// checkContext verifies that the context bytes of a chunk are the fork digest at the slot of its message
+func (c *Client) checkContext(contextBytes [4]byte, slot types.Slot) error {
+	if c.chain == nil {
+		return nil
+	}
+	expected, err := c.chain.SlotDigest(slot)
+	if err != nil {
+		return err
+	}
+	if contextBytes != expected {
+		return fmt.Errorf("%w: chunk for slot %d has context bytes %#x, expected %#x", ErrWrongForkDigest, slot, contextBytes, expected)
+	}
+	return nil
+}
+
+func (c *Client) readStatusCodeNoDeadline(stream network.Stream) (uint8, string, error) {
+	b := make([]byte, 1)
+	_, err := stream.Read(b)
+	if err != nil {
+		return 0, "", err
+	}
+	if b[0] == responseCodeSuccess {
+		return 0, "", nil
+	}
+	msg := &p2ptypes.ErrorMessage{}
+	if err := c.encoding.DecodeWithMaxLength(stream, msg); err != nil {
+		return 0, "", err
+	}
+	return b[0], string(*msg), nil
+}
@@ -0,0 +1,59 @@
+package p2pclient
+
+import (
+	"context"
+
+	"github.com/Inphi/eip4844-interop/shared/sidecar"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
+)
+
+// PeerSource is a sidecar.BlobSource that sends RPC requests to a single peer.
+// Ranges are split into pages of at most MaxRequestBlobsSidecars slots, and rate limited requests are retried.
+type PeerSource struct {
+	c        *Client
+	pid      peer.ID
+	pageSize uint64
+}
+
+var _ sidecar.BlobSource = (*PeerSource)(nil)
+
+// Source returns a BlobSource that retrieves sidecars from pid
+func (c *Client) Source(pid peer.ID) *PeerSource {
+	return &PeerSource{c: c, pid: pid, pageSize: MaxRequestBlobsSidecars}
+}
+
+// WithPageSize overrides the number of slots requested at once, for peers with tighter limits
+func (s *PeerSource) WithPageSize(n uint64) *PeerSource {
+	if n > 0 {
+		s.pageSize = n
+	}
+	return s
+}
+
+func (s *PeerSource) Name() string {
+	return "p2p"
+}
+
+func (s *PeerSource) SidecarsByRange(ctx context.Context, start, count uint64) ([]*ethpb.BlobsSidecar, error) {
+	return s.c.pagedSidecarsByRange(ctx, s.pid, start, count, s.pageSize)
+}
+
+func (s *PeerSource) SidecarsByRoot(ctx context.Context, roots []common.Hash) ([]*ethpb.BlobsSidecar, error) {
+	req := make([][32]byte, len(roots))
+	for i, root := range roots {
+		req[i] = root
+	}
+	resp, err := s.c.BeaconBlockAndBlobsSidecarByRoot(ctx, s.pid, req)
+	if err != nil {
+		return nil, err
+	}
+	var sidecars []*ethpb.BlobsSidecar
+	for _, r := range resp {
+		if r.BlobsSidecar != nil {
+			sidecars = append(sidecars, r.BlobsSidecar)
+		}
+	}
+	return sidecars, nil
+}
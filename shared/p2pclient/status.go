@@ -0,0 +1,81 @@
+package p2pclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/prysmaticlabs/prysm/v3/beacon-chain/p2p"
+	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
+)
+
+// ErrWrongForkDigest is returned when a peer is on a different fork, or sends a chunk with unexpected context bytes
+var ErrWrongForkDigest = errors.New("wrong fork digest")
+
+// status describes us as a node sitting at genesis, which every client accepts and never tries to sync from
+func (c *Client) status() (*ethpb.Status, error) {
+	digest, err := c.chain.ForkDigest(c.chain.CurrentEpoch())
+	if err != nil {
+		return nil, err
+	}
+	return &ethpb.Status{
+		ForkDigest:     digest[:],
+		FinalizedRoot:  make([]byte, 32),
+		FinalizedEpoch: 0,
+		HeadRoot:       make([]byte, 32),
+		HeadSlot:       0,
+	}, nil
+}
+
+// exchangeStatus sends our Status to pid and checks that it is on our fork
+func (c *Client) exchangeStatus(ctx context.Context, pid peer.ID) (*ethpb.Status, error) {
+	ours, err := c.status()
+	if err != nil {
+		return nil, err
+	}
+	var theirs *ethpb.Status
+	err = c.request(ctx, pid, p2p.RPCStatusTopicV1, ours, func() responseChunk {
+		theirs = new(ethpb.Status)
+		return responseChunk{msg: theirs}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: status request", err)
+	}
+	if theirs == nil {
+		return nil, errors.New("peer sent no status")
+	}
+	if !bytes.Equal(theirs.ForkDigest, ours.ForkDigest) {
+		return nil, fmt.Errorf("%w: peer is on %#x, expected %#x", ErrWrongForkDigest, theirs.ForkDigest, ours.ForkDigest)
+	}
+
+	c.mu.Lock()
+	c.statuses[pid] = theirs
+	c.mu.Unlock()
+	return theirs, nil
+}
+
+// PeerStatus returns the Status pid sent when we connected to it, or nil if no handshake took place
+func (c *Client) PeerStatus(pid peer.ID) *ethpb.Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.statuses[pid]
+}
+
+// statusHandler answers the Status requests of peers with our own Status
+func (c *Client) statusHandler(_ context.Context, _ interface{}, stream network.Stream) error {
+	defer closeStream(stream)
+	status, err := c.status()
+	if err != nil {
+		return err
+	}
+	if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
+		return err
+	}
+	if _, err := c.encoding.EncodeWithMaxLength(stream, status); err != nil {
+		return fmt.Errorf("%w: statusHandler stream write", err)
+	}
+	return nil
+}
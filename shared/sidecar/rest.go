@@ -0,0 +1,140 @@
+package sidecar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/prysmaticlabs/prysm/v3/api/client/beacon"
+	types "github.com/prysmaticlabs/prysm/v3/consensus-types/primitives"
+	enginev1 "github.com/prysmaticlabs/prysm/v3/proto/engine/v1"
+	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
+)
+
+// DefaultSidecarPath is the beacon API route serving the blobs sidecar of a block
+const DefaultSidecarPath = "/eth/v1/beacon/blobs_sidecars/%s"
+
+// RESTSource retrieves sidecars from the HTTP API of a beacon node, bypassing p2p entirely
+type RESTSource struct {
+	client *beacon.Client
+	hc     *http.Client
+	path   string
+}
+
+var _ BlobSource = (*RESTSource)(nil)
+
+// NewRESTSource returns a BlobSource backed by the beacon node that client talks to
+func NewRESTSource(client *beacon.Client) *RESTSource {
+	return &RESTSource{
+		client: client,
+		hc:     http.DefaultClient,
+		path:   DefaultSidecarPath,
+	}
+}
+
+// WithPath overrides the sidecar route, for clients that serve it elsewhere. path must contain a single %s for the block id.
+func (s *RESTSource) WithPath(path string) *RESTSource {
+	s.path = path
+	return s
+}
+
+func (s *RESTSource) Name() string {
+	return "rest"
+}
+
+func (s *RESTSource) SidecarsByRange(ctx context.Context, start, count uint64) ([]*ethpb.BlobsSidecar, error) {
+	var sidecars []*ethpb.BlobsSidecar
+	for slot := start; slot < start+count; slot++ {
+		sidecar, err := s.get(ctx, strconv.FormatUint(slot, 10))
+		if err != nil {
+			return nil, err
+		}
+		if sidecar != nil {
+			sidecars = append(sidecars, sidecar)
+		}
+	}
+	return sidecars, nil
+}
+
+func (s *RESTSource) SidecarsByRoot(ctx context.Context, roots []common.Hash) ([]*ethpb.BlobsSidecar, error) {
+	var sidecars []*ethpb.BlobsSidecar
+	for _, root := range roots {
+		sidecar, err := s.get(ctx, root.Hex())
+		if err != nil {
+			return nil, err
+		}
+		if sidecar != nil {
+			sidecars = append(sidecars, sidecar)
+		}
+	}
+	return sidecars, nil
+}
+
+type restSidecar struct {
+	BeaconBlockRoot hexutil.Bytes   `json:"beacon_block_root"`
+	BeaconBlockSlot string          `json:"beacon_block_slot"`
+	Blobs           []hexutil.Bytes `json:"blobs"`
+	// clients disagree on the name of the proof
+	KzgAggregatedProof hexutil.Bytes `json:"kzg_aggregated_proof"`
+	AggregatedProof    hexutil.Bytes `json:"aggregated_proof"`
+}
+
+// get returns the sidecar of the given block, or nil if the block or its sidecar doesn't exist
+func (s *RESTSource) get(ctx context.Context, blockID string) (*ethpb.BlobsSidecar, error) {
+	url := strings.TrimSuffix(s.client.NodeURL(), "/") + fmt.Sprintf(s.path, blockID)
+	var resp struct {
+		Data restSidecar `json:"data"`
+	}
+	if found, err := getJSON(ctx, s.hc, url, &resp); err != nil || !found {
+		return nil, err
+	}
+	data := resp.Data
+	slot, err := strconv.ParseUint(data.BeaconBlockSlot, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid slot %q", url, data.BeaconBlockSlot)
+	}
+	proof := data.KzgAggregatedProof
+	if len(proof) == 0 {
+		proof = data.AggregatedProof
+	}
+	sidecar := &ethpb.BlobsSidecar{
+		BeaconBlockRoot: data.BeaconBlockRoot,
+		BeaconBlockSlot: types.Slot(slot),
+		AggregatedProof: proof,
+	}
+	for _, blob := range data.Blobs {
+		sidecar.Blobs = append(sidecar.Blobs, &enginev1.Blob{Data: blob})
+	}
+	return sidecar, nil
+}
+
+// getJSON decodes the JSON response to a GET of url into out. found is false if the resource doesn't exist.
+func getJSON(ctx context.Context, hc *http.Client, url string, out interface{}) (found bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	r, err := hc.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if r.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(r.Body, 1024))
+		return false, fmt.Errorf("%s: unexpected status %s: %s", url, r.Status, body)
+	}
+	if err := json.NewDecoder(r.Body).Decode(out); err != nil {
+		return false, fmt.Errorf("%s: %w", url, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,71 @@
+// Package sidecar retrieves blobs sidecars from beacon nodes.
+package sidecar
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/ethereum/go-ethereum/common"
+	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
+)
+
+// BlobSource retrieves the blobs sidecars of beacon blocks.
+// Slots and blocks without a sidecar are left out of the results.
+type BlobSource interface {
+	// SidecarsByRange returns the sidecars of the blocks in the count slots starting at start
+	SidecarsByRange(ctx context.Context, start, count uint64) ([]*ethpb.BlobsSidecar, error)
+	// SidecarsByRoot returns the sidecars of the blocks with the given roots
+	SidecarsByRoot(ctx context.Context, roots []common.Hash) ([]*ethpb.BlobsSidecar, error)
+	// Name identifies the source in logs and reports
+	Name() string
+}
+
+// Sidecar is a downloaded blobs sidecar, with its blobs flattened
+type Sidecar struct {
+	Slot            uint64
+	BlockRoot       common.Hash
+	Blobs           [][]byte
+	AggregatedProof []byte
+}
+
+// FromProto converts a sidecar received from a beacon node
+func FromProto(sc *ethpb.BlobsSidecar) *Sidecar {
+	s := &Sidecar{
+		Slot:            uint64(sc.BeaconBlockSlot),
+		BlockRoot:       common.BytesToHash(sc.BeaconBlockRoot),
+		Blobs:           make([][]byte, len(sc.Blobs)),
+		AggregatedProof: sc.AggregatedProof,
+	}
+	for i, blob := range sc.Blobs {
+		s.Blobs[i] = blob.Data
+	}
+	return s
+}
+
+// Data decodes the blobs of the sidecar and concatenates their payloads
+func (s *Sidecar) Data() ([]byte, error) {
+	var data []byte
+	for i := range s.Blobs {
+		d, err := s.BlobData(i)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, d...)
+	}
+	return data, nil
+}
+
+// BlobData decodes the payload of the i-th blob
+func (s *Sidecar) BlobData(i int) ([]byte, error) {
+	if err := shared.ValidateBlob(s.Blobs[i]); err != nil {
+		return nil, fmt.Errorf("blob %d of slot %d: %w", i, s.Slot, err)
+	}
+	data, err := io.ReadAll(shared.NewBlobReader(bytes.NewReader(s.Blobs[i])))
+	if err != nil {
+		return nil, fmt.Errorf("blob %d of slot %d: %w", i, s.Slot, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,193 @@
+package sidecar
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/protolambda/go-kzg/eth"
+	"github.com/prysmaticlabs/prysm/v3/api/client/beacon"
+	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
+)
+
+// Block holds the parts of a beacon block that its sidecar is verified against
+type Block struct {
+	Root        common.Hash
+	Slot        uint64
+	Commitments [][]byte
+}
+
+// Mismatch describes one way in which a sidecar disagrees with its beacon block
+type Mismatch struct {
+	Field string
+	// Index is the blob the mismatch refers to, or -1 if it concerns the whole sidecar
+	Index    int
+	Expected string
+	Actual   string
+}
+
+func (m Mismatch) String() string {
+	field := m.Field
+	if m.Index >= 0 {
+		field = fmt.Sprintf("%s[%d]", m.Field, m.Index)
+	}
+	return fmt.Sprintf("%s: expected %s, got %s", field, m.Expected, m.Actual)
+}
+
+// VerificationError lists every mismatch found between a sidecar and its beacon block
+type VerificationError struct {
+	Slot       uint64
+	Mismatches []Mismatch
+}
+
+func (e *VerificationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "sidecar for slot %d has %d mismatches", e.Slot, len(e.Mismatches))
+	for _, m := range e.Mismatches {
+		b.WriteString("\n\t")
+		b.WriteString(m.String())
+	}
+	return b.String()
+}
+
+// Verify checks that a sidecar belongs to block: its block root and slot must match, the commitments
+// recomputed from its blobs must equal the block's, and its aggregated proof must be valid for them.
+// It returns a *VerificationError listing every mismatch.
+func Verify(sc *ethpb.BlobsSidecar, block Block) error {
+	var mismatches []Mismatch
+	add := func(field string, index int, expected, actual interface{}) {
+		mismatches = append(mismatches, Mismatch{
+			Field:    field,
+			Index:    index,
+			Expected: fmt.Sprint(expected),
+			Actual:   fmt.Sprint(actual),
+		})
+	}
+
+	if !bytes.Equal(sc.BeaconBlockRoot, block.Root[:]) {
+		add("beacon_block_root", -1, block.Root, hexutil.Bytes(sc.BeaconBlockRoot))
+	}
+	if uint64(sc.BeaconBlockSlot) != block.Slot {
+		add("beacon_block_slot", -1, block.Slot, sc.BeaconBlockSlot)
+	}
+	if len(sc.Blobs) != len(block.Commitments) {
+		add("blobs", -1, fmt.Sprintf("%d blobs", len(block.Commitments)), fmt.Sprintf("%d blobs", len(sc.Blobs)))
+	}
+
+	blobsMatch := len(sc.Blobs) == len(block.Commitments)
+	blobs := make(types.Blobs, 0, len(sc.Blobs))
+	commitments := make(types.BlobKzgs, 0, len(block.Commitments))
+	for i, b := range sc.Blobs {
+		blob, err := shared.BlobFromBytes(b.Data)
+		if err != nil {
+			add("blob", i, fmt.Sprintf("%d bytes", params.FieldElementsPerBlob*32), fmt.Sprintf("%d bytes", len(b.Data)))
+			blobsMatch = false
+			continue
+		}
+		c, ok := eth.BlobToKZGCommitment(blob)
+		if !ok {
+			add("blob", i, "canonical field elements", "non-canonical field elements")
+			blobsMatch = false
+			continue
+		}
+		if i >= len(block.Commitments) {
+			continue
+		}
+		expected := block.Commitments[i]
+		if !bytes.Equal(c[:], expected) {
+			add("commitment", i, hexutil.Bytes(expected), types.KZGCommitment(c))
+			blobsMatch = false
+		}
+		blobs = append(blobs, blob)
+		commitments = append(commitments, types.KZGCommitment(c))
+	}
+
+	// the proof is only meaningful if every blob matches its commitment
+	if len(sc.AggregatedProof) != len(eth.KZGProof{}) {
+		add("aggregated_proof", -1, fmt.Sprintf("%d bytes", len(eth.KZGProof{})), fmt.Sprintf("%d bytes", len(sc.AggregatedProof)))
+	} else if blobsMatch {
+		var proof eth.KZGProof
+		copy(proof[:], sc.AggregatedProof)
+		ok, err := eth.VerifyAggregateKZGProof(blobs, commitments, proof)
+		if err != nil {
+			add("aggregated_proof", -1, "valid proof", err)
+		} else if !ok {
+			add("aggregated_proof", -1, "valid proof", fmt.Sprintf("invalid proof %v", hexutil.Bytes(sc.AggregatedProof)))
+		}
+	}
+
+	if len(mismatches) != 0 {
+		return &VerificationError{Slot: uint64(sc.BeaconBlockSlot), Mismatches: mismatches}
+	}
+	return nil
+}
+
+// FetchBlock returns the block with the given id, a slot or a block root, from the HTTP API of the beacon node that
+// client talks to
+func FetchBlock(ctx context.Context, client *beacon.Client, blockID string) (Block, error) {
+	base := strings.TrimSuffix(client.NodeURL(), "/")
+	var block struct {
+		Data struct {
+			Message struct {
+				Slot string `json:"slot"`
+				Body struct {
+					BlobKzgCommitments []hexutil.Bytes `json:"blob_kzg_commitments"`
+				} `json:"body"`
+			} `json:"message"`
+		} `json:"data"`
+	}
+	var root struct {
+		Data struct {
+			Root common.Hash `json:"root"`
+		} `json:"data"`
+	}
+	get := func(url string, out interface{}) error {
+		found, err := getJSON(ctx, http.DefaultClient, url, out)
+		if err == nil && !found {
+			err = fmt.Errorf("block %s not found", blockID)
+		}
+		return err
+	}
+	if err := get(base+"/eth/v2/beacon/blocks/"+blockID, &block); err != nil {
+		return Block{}, err
+	}
+	if err := get(base+"/eth/v1/beacon/blocks/"+blockID+"/root", &root); err != nil {
+		return Block{}, err
+	}
+	slot, err := strconv.ParseUint(block.Data.Message.Slot, 10, 64)
+	if err != nil {
+		return Block{}, fmt.Errorf("block %s: invalid slot %q", blockID, block.Data.Message.Slot)
+	}
+	b := Block{Root: root.Data.Root, Slot: slot}
+	for _, c := range block.Data.Message.Body.BlobKzgCommitments {
+		b.Commitments = append(b.Commitments, c)
+	}
+	return b, nil
+}
+
+// VerifyCanonical checks sc against the canonical block at its slot, fetched through client
+func VerifyCanonical(ctx context.Context, client *beacon.Client, sc *ethpb.BlobsSidecar) error {
+	block, err := FetchBlock(ctx, client, strconv.FormatUint(uint64(sc.BeaconBlockSlot), 10))
+	if err != nil {
+		return err
+	}
+	return Verify(sc, block)
+}
+
+// VerifyByRoot checks sc against the block with its root, fetched through client. Unlike VerifyCanonical, the block
+// doesn't need to be canonical.
+func VerifyByRoot(ctx context.Context, client *beacon.Client, sc *ethpb.BlobsSidecar) error {
+	block, err := FetchBlock(ctx, client, hexutil.Encode(sc.BeaconBlockRoot))
+	if err != nil {
+		return err
+	}
+	return Verify(sc, block)
+}
@@ -8,19 +8,18 @@ import (
 	"time"
 
 	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/Inphi/eip4844-interop/shared/blobtx"
 	"github.com/Inphi/eip4844-interop/tests/ctrl"
 	"github.com/Inphi/eip4844-interop/tests/util"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/holiman/uint256"
-	"github.com/protolambda/ztyp/view"
+	"github.com/prysmaticlabs/prysm/v3/api/client/beacon"
 )
 
 func GetBlobs() types.Blobs {
-	// dummy data for the test
-	return shared.EncodeBlobs([]byte("EKANS"))
+	// dummy data for the test, ending in zeros that must survive the round trip
+	return shared.EncodeBlobs([]byte("EKANS\x00\x00\x00"))
 }
 
 // 1. Uploads blobs
@@ -42,11 +41,11 @@ func main() {
 
 	ethClient, err := ctrl.GetExecutionClient(ctx)
 	if err != nil {
-		log.Fatalf("unable to get execution client: %v", err)
+		util.Fatalf("unable to get execution client: %v", err)
 	}
 	beaconClient, err := ctrl.GetBeaconNodeClient(ctx)
 	if err != nil {
-		log.Fatalf("unable to get beacon client: %v", err)
+		util.Fatalf("unable to get beacon client: %v", err)
 	}
 
 	blobs := GetBlobs()
@@ -54,25 +53,43 @@ func main() {
 	// Retrieve the current slot to being our blobs search on the beacon chain
 	startSlot := util.GetHeadSlot(ctx, beaconClient)
 
-	chainID := env.GethChainConfig.ChainID
-	UploadBlobs(ctx, ethClient, chainID, blobs)
-	util.WaitForNextSlots(ctx, beaconClient, 1)
-	slot := util.FindBlobSlot(ctx, beaconClient, startSlot)
-
 	multiaddr, err := shared.GetBeaconMultiAddress()
 	if err != nil {
-		log.Fatalf("unable to get beacon multiaddr: %v", err)
+		util.Fatalf("unable to get beacon multiaddr: %v", err)
 	}
 	followerMultiaddr, err := shared.GetBeaconFollowerMultiAddress()
 	if err != nil {
-		log.Fatalf("unable to get beacon multiaddr: %v", err)
+		util.Fatalf("unable to get beacon multiaddr: %v", err)
 	}
+	gossip := util.WatchGossip(ctx, followerMultiaddr)
+
+	chainID := env.GethChainConfig.ChainID
+	txHash := UploadBlobs(ctx, ethClient, chainID, blobs)
+	util.WaitForNextSlots(ctx, beaconClient, 1)
+	slot := util.FindBlobSlot(ctx, beaconClient, startSlot)
+
+	log.Printf("checking blob was gossiped to the beacon node follower")
+	gossip.AssertBlobsGossiped(slot, len(blobs))
 
 	log.Printf("checking blob from beacon node")
 	downloadedData := util.DownloadBlobs(ctx, slot, 1, multiaddr)
 	downloadedBlobs := shared.EncodeBlobs(downloadedData)
 	util.AssertBlobsEquals(blobs, downloadedBlobs)
 
+	log.Printf("checking blob of tx %v from beacon node", txHash)
+	downloadedData = util.DownloadTransactionBlobs(ctx, ethClient, "http://"+shared.BeaconAPI, txHash, multiaddr)
+	downloadedBlobs = shared.EncodeBlobs(downloadedData)
+	util.AssertBlobsEquals(blobs, downloadedBlobs)
+
+	log.Printf("checking blob by root from beacon node")
+	root, err := beaconClient.GetBlockRoot(ctx, beacon.IdFromSlot(slot))
+	if err != nil {
+		util.Fatalf("unable to get block root of slot %d: %v", slot, err)
+	}
+	downloadedData = util.DownloadBlobsByRoot(ctx, [][32]byte{root}, multiaddr)
+	downloadedBlobs = shared.EncodeBlobs(downloadedData)
+	util.AssertBlobsEquals(blobs, downloadedBlobs)
+
 	log.Printf("checking blob from beacon node follower")
 	sleep := time.Second * 2 * time.Duration(env.BeaconChainConfig.SecondsPerSlot)
 	log.Printf("wait a bit to sync: %v", sleep)
@@ -80,57 +97,24 @@ func main() {
 	downloadedData = util.DownloadBlobs(ctx, slot, 1, followerMultiaddr)
 	downloadedBlobs = shared.EncodeBlobs(downloadedData)
 	util.AssertBlobsEquals(blobs, downloadedBlobs)
-}
-
-func UploadBlobs(ctx context.Context, client *ethclient.Client, chainID *big.Int, blobs types.Blobs) {
-	signer := types.NewDankSigner(chainID)
-
-	key, err := crypto.HexToECDSA(shared.PrivateKey)
-	if err != nil {
-		log.Fatalf("Failed to load private key: %v", err)
-	}
 
-	nonce, err := client.PendingNonceAt(ctx, crypto.PubkeyToAddress(key.PublicKey))
-	if err != nil {
-		log.Fatalf("Error getting nonce: %v", err)
-	}
-	log.Printf("Nonce: %d", nonce)
-
-	commitments, versionedHashes, aggregatedProof, err := blobs.ComputeCommitmentsAndAggregatedProof()
-
-	to := common.HexToAddress("ffb38a7a99e3e2335be83fc74b7faa19d5531243")
-	txData := types.SignedBlobTx{
-		Message: types.BlobTxMessage{
-			ChainID:             view.Uint256View(*uint256.NewInt(chainID.Uint64())),
-			Nonce:               view.Uint64View(nonce),
-			Gas:                 210000,
-			GasFeeCap:           view.Uint256View(*uint256.NewInt(5000000000)),
-			GasTipCap:           view.Uint256View(*uint256.NewInt(5000000000)),
-			MaxFeePerDataGas:    view.Uint256View(*uint256.NewInt(3000000000)), // needs to be at least the min fee
-			Value:               view.Uint256View(*uint256.NewInt(12345678)),
-			To:                  types.AddressOptionalSSZ{Address: (*types.AddressSSZ)(&to)},
-			BlobVersionedHashes: versionedHashes,
-		},
-	}
+	log.Printf("checking blob from beacon node follower found through discv5")
+	downloadedData = util.DownloadBlobsFrom(ctx, util.DiscoverP2PSource(ctx, "http://"+shared.BeaconFollowerAPI), slot, 1)
+	downloadedBlobs = shared.EncodeBlobs(downloadedData)
+	util.AssertBlobsEquals(blobs, downloadedBlobs)
+}
 
-	wrapData := types.BlobTxWrapData{
-		BlobKzgs:           commitments,
-		Blobs:              blobs,
-		KzgAggregatedProof: aggregatedProof,
-	}
-	tx := types.NewTx(&txData, types.WithTxWrapData(&wrapData))
-	tx, err = types.SignTx(tx, signer, key)
-	if err != nil {
-		log.Fatalf("Error signing tx: %v", err)
-	}
-	err = client.SendTransaction(ctx, tx)
+func UploadBlobs(ctx context.Context, client *ethclient.Client, chainID *big.Int, blobs types.Blobs) common.Hash {
+	builder := blobtx.NewBuilder(client, blobtx.WithChainID(chainID))
+	tx, err := builder.Build(ctx, blobtx.WithBlobs(blobs))
 	if err != nil {
-		log.Fatalf("Error sending tx: %v", err)
+		util.Fatalf("Error building tx: %v", err)
 	}
-	log.Printf("Transaction submitted. hash=%v", tx.Hash())
+	log.Printf("Nonce: %d", tx.Nonce())
 
 	log.Printf("Waiting for transaction (%v) to be included...", tx.Hash())
-	if _, err := shared.WaitForReceipt(ctx, client, tx.Hash()); err != nil {
-		log.Fatalf("Error waiting for transaction receipt %v: %v", tx.Hash(), err)
+	if _, err := builder.SendAndWait(ctx, tx); err != nil {
+		util.Fatalf("Error sending tx %v: %v", tx.Hash(), err)
 	}
+	return tx.Hash()
 }
@@ -0,0 +1,145 @@
+package ctrl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/Inphi/eip4844-interop/tests/util"
+)
+
+// collectArtifactsTimeout is the time the failure hooks have before the process exits
+const collectArtifactsTimeout = util.FailureHooksTimeout
+
+// beaconStateEndpoints are the beacon API endpoints recorded for every beacon node on failure
+var beaconStateEndpoints = map[string]string{
+	"head":                 "/eth/v1/beacon/headers/head",
+	"finality_checkpoints": "/eth/v1/beacon/states/head/finality_checkpoints",
+	"peers":                "/eth/v1/node/peers",
+	"syncing":              "/eth/v1/node/syncing",
+	"identity":             "/eth/v1/node/identity",
+}
+
+// ArtifactsDir returns the directory holding the artifacts of failed tests, ARTIFACTS_DIR or artifacts in the base dir
+func ArtifactsDir() string {
+	if dir := os.Getenv("ARTIFACTS_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(shared.GetBaseDir(), "artifacts")
+}
+
+// installFailureHook collects the artifacts of env into a timestamped directory when the test fails
+func (env *TestEnvironment) installFailureHook(clientName string) {
+	util.OnFailure(func() {
+		name := fmt.Sprintf("%s-%s-%s", filepath.Base(os.Args[0]), clientName, time.Now().Format("20060102-150405"))
+		dir := filepath.Join(ArtifactsDir(), name)
+		ctx, cancel := context.WithTimeout(context.Background(), collectArtifactsTimeout)
+		defer cancel()
+		log.Printf("collecting test artifacts into %s", dir)
+		if err := env.CollectArtifacts(ctx, dir); err != nil {
+			log.Printf("failed to collect test artifacts: %v", err)
+		}
+	})
+}
+
+// CollectArtifacts writes the logs of every service, the generated configs and the head, finality and peers of each
+// beacon node into dir. It collects as much as it can, returning the first error.
+func (env *TestEnvironment) CollectArtifacts(ctx context.Context, dir string) error {
+	var firstErr error
+	record := func(err error) {
+		if err != nil {
+			log.Printf("artifacts: %v", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	logsDir := filepath.Join(dir, "logs")
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		return err
+	}
+	for _, svc := range env.services() {
+		record(writeArtifact(filepath.Join(logsDir, svc.Name()+".log"), func(w io.Writer) error {
+			return svc.Logs(ctx, w)
+		}))
+	}
+	if ProcessBackend() == nil {
+		// the containers the environment doesn't manage directly
+		for _, svc := range []string{"genesis-generator", "bootnode"} {
+			svc := svc
+			record(writeArtifact(filepath.Join(logsDir, svc+".log"), func(w io.Writer) error {
+				return DefaultOrchestrator().Logs(ctx, svc, w)
+			}))
+		}
+	}
+
+	record(copyDir(generatedConfigDir(), filepath.Join(dir, "configs")))
+	record(copyFile(shared.GenesisGeneratorValuesFilepath(), filepath.Join(dir, "configs", "values.env")))
+
+	beaconNodes := map[string]string{"beacon-node": shared.BeaconAPI}
+	if env.BeaconNodeFollower != nil {
+		beaconNodes["beacon-node-follower"] = shared.BeaconFollowerAPI
+	}
+	for node, api := range beaconNodes {
+		for name, path := range beaconStateEndpoints {
+			url := "http://" + api + path
+			record(writeArtifact(filepath.Join(dir, "beacon", node, name+".json"), func(w io.Writer) error {
+				return fetchArtifact(ctx, url, w)
+			}))
+		}
+	}
+	return firstErr
+}
+
+// services returns the services of the environment that are set
+func (env *TestEnvironment) services() []Service {
+	var svcs []Service
+	for _, svc := range []Service{env.GethNode, env.GethNode2, env.BeaconNode, env.BeaconNodeFollower, env.ValidatorNode} {
+		if svc != nil {
+			svcs = append(svcs, svc)
+		}
+	}
+	return svcs
+}
+
+func writeArtifact(path string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := write(f); err != nil {
+		return fmt.Errorf("%w: writing %s", err, path)
+	}
+	return nil
+}
+
+func fetchArtifact(ctx context.Context, url string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GET %s: %s: %s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -3,12 +3,15 @@ package ctrl
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"time"
 
 	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/Inphi/eip4844-interop/shared/p2pclient"
 	"github.com/Inphi/eip4844-interop/tests/util"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/prysmaticlabs/prysm/v3/api/client/beacon"
@@ -36,20 +39,21 @@ func InitEnvForClient(clientName string) *TestEnvironment {
 	case "lighthouse":
 		consensusClientEnvironment = newLighthouseTestEnvironment()
 	default:
-		log.Fatalf("unknown client %s", clientName)
+		util.Fatalf("unknown client %s", clientName)
 	}
+	consensusClientEnvironment.installFailureHook(clientName)
 	return consensusClientEnvironment
 }
 
 func InitE2ETest(clientName string) {
 	ctx := context.Background()
 	if err := StopDevnet(); err != nil {
-		log.Fatalf("unable to stop devnet: %v", err)
+		util.Fatalf("unable to stop devnet: %v", err)
 	}
 
 	env := InitEnvForClient(clientName)
 	if err := env.StartAll(ctx); err != nil {
-		log.Fatalf("unable to start environment: %v", err)
+		util.Fatalf("unable to start environment: %v", err)
 	}
 }
 
@@ -59,14 +63,14 @@ func WaitForShardingFork() {
 	config := GetEnv().GethChainConfig
 	eip4844ForkTime := config.ShardingForkTime
 	if eip4844ForkTime == nil {
-		log.Fatalf("shardingForkTime is not set in configuration")
+		util.Fatalf("shardingForkTime is not set in configuration")
 	}
 
 	stallTimeout := 60 * time.Minute
 
 	client, err := GetExecutionClient(ctx)
 	if err != nil {
-		log.Fatalf("unable to retrive beacon node client: %v", err)
+		util.Fatalf("unable to retrive beacon node client: %v", err)
 	}
 
 	log.Printf("waiting for sharding fork time...")
@@ -75,7 +79,7 @@ func WaitForShardingFork() {
 	for {
 		b, err := client.BlockByNumber(ctx, nil)
 		if err != nil {
-			log.Fatalf("ethclient.BlockByNumber: %v", err)
+			util.Fatalf("ethclient.BlockByNumber: %v", err)
 		}
 		if b.Time() >= *eip4844ForkTime {
 			break
@@ -85,7 +89,7 @@ func WaitForShardingFork() {
 			lastBn = b.NumberU64()
 			lastUpdate = time.Now()
 		} else if time.Since(lastUpdate) > stallTimeout {
-			log.Fatalf("Chain is stalled on block %v", b.NumberU64())
+			util.Fatalf("Chain is stalled on block %v", b.NumberU64())
 		}
 		time.Sleep(time.Second * 1)
 	}
@@ -98,11 +102,11 @@ func ReadGethChainConfig() *params.ChainConfig {
 func ReadGethChainConfigFromPath(path string) *params.ChainConfig {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatalf("unable to read geth chain config file at %v: %v", path, err)
+		util.Fatalf("unable to read geth chain config file at %v: %v", path, err)
 	}
 	var genesis core.Genesis
 	if err := json.Unmarshal(data, &genesis); err != nil {
-		log.Fatalf("invalid chain config at %v: %v", path, err)
+		util.Fatalf("invalid chain config at %v: %v", path, err)
 	}
 	return genesis.Config
 }
@@ -114,11 +118,11 @@ func ReadBeaconChainConfig() *BeaconChainConfig {
 func ReadBeaconChainConfigFromPath(path string) *BeaconChainConfig {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatalf("unable to read beacon chain config file at %v: %v", path, err)
+		util.Fatalf("unable to read beacon chain config file at %v: %v", path, err)
 	}
 	var config BeaconChainConfig
 	if err := yaml.Unmarshal(data, &config); err != nil {
-		log.Fatalf("invalid beacon chain config file at %v: %v", path, err)
+		util.Fatalf("invalid beacon chain config file at %v: %v", path, err)
 	}
 	return &config
 }
@@ -151,22 +155,73 @@ func WaitForEip4844ForkEpoch() {
 	// TODO: query /eth/v1/config/spec for time parameters
 	eip4844Slot := config.Eip4844ForkEpoch * config.SlotsPerEpoch
 	if err := WaitForSlot(ctx, types.Slot(eip4844Slot)); err != nil {
-		log.Fatal(err)
+		util.Fatal(err)
 	}
+	configureP2PClient(ctx)
+}
+
+// configureP2PClient hands the chain config to the p2p client used to download blobs
+func configureP2PClient(ctx context.Context) {
+	client, err := GetBeaconNodeClient(ctx)
+	if err != nil {
+		util.Fatalf("unable to get beacon client: %v", err)
+	}
+	genesisValidatorsRoot, genesisTime, err := p2pclient.FetchGenesis(ctx, client)
+	if err != nil {
+		util.Fatalf("unable to get genesis: %v", err)
+	}
+	chain, err := GetEnv().BeaconChainConfig.P2PChainConfig(genesisValidatorsRoot, genesisTime)
+	if err != nil {
+		util.Fatalf("invalid beacon chain config: %v", err)
+	}
+	util.SetP2PChainConfig(chain)
 }
 
 type BeaconChainConfig struct {
 	AltairForkEpoch         uint64 `yaml:"ALTAIR_FORK_EPOCH"`
 	BellatrixForkEpoch      uint64 `yaml:"BELLATRIX_FORK_EPOCH"`
+	CapellaForkEpoch        uint64 `yaml:"CAPELLA_FORK_EPOCH"`
 	Eip4844ForkEpoch        uint64 `yaml:"EIP4844_FORK_EPOCH"`
 	SlotsPerEpoch           uint64 `yaml:"SLOTS_PER_EPOCH"`
 	SecondsPerSlot          uint64 `yaml:"SECONDS_PER_SLOT"`
 	TerminalTotalDifficulty uint64 `yaml:"TERMINAL_TOTAL_DIFFICULTY"`
+	GenesisForkVersion      string `yaml:"GENESIS_FORK_VERSION"`
+	AltairForkVersion       string `yaml:"ALTAIR_FORK_VERSION"`
 	BellatrixForkVersion    string `yaml:"BELLATRIX_FORK_VERSION"`
 	CapellaForkVersion      string `yaml:"CAPELLA_FORK_VERSION"`
 	EIP4844ForkVersion      string `yaml:"EIP4844_FORK_VERSION"`
 }
 
+// P2PChainConfig returns the fork schedule of the config along with the genesis of the running chain
+func (c *BeaconChainConfig) P2PChainConfig(genesisValidatorsRoot [32]byte, genesisTime uint64) (*p2pclient.ChainConfig, error) {
+	chain := &p2pclient.ChainConfig{
+		GenesisValidatorsRoot: genesisValidatorsRoot,
+		GenesisTime:           genesisTime,
+		SecondsPerSlot:        c.SecondsPerSlot,
+		SlotsPerEpoch:         c.SlotsPerEpoch,
+	}
+	forks := []struct {
+		epoch   uint64
+		version string
+	}{
+		{0, c.GenesisForkVersion},
+		{c.AltairForkEpoch, c.AltairForkVersion},
+		{c.BellatrixForkEpoch, c.BellatrixForkVersion},
+		{c.CapellaForkEpoch, c.CapellaForkVersion},
+		{c.Eip4844ForkEpoch, c.EIP4844ForkVersion},
+	}
+	for _, f := range forks {
+		b, err := hexutil.Decode(f.version)
+		if err != nil || len(b) != 4 {
+			return nil, fmt.Errorf("invalid fork version %q", f.version)
+		}
+		fork := p2pclient.Fork{Epoch: f.epoch}
+		copy(fork.Version[:], b)
+		chain.Forks = append(chain.Forks, fork)
+	}
+	return chain, nil
+}
+
 type TestEnvironment struct {
 	GethChainConfig    *params.ChainConfig
 	BeaconChainConfig  *BeaconChainConfig
@@ -178,8 +233,14 @@ type TestEnvironment struct {
 }
 
 func setupGeneratedConfigs() {
+	if p := ProcessBackend(); p != nil {
+		if err := p.checkGeneratedConfigs(); err != nil {
+			util.Fatalf("missing generated configs: %v", err)
+		}
+		return
+	}
 	if err := StartServices("genesis-generator"); err != nil {
-		log.Fatalf("failed to start genesis-generator service: %v", err)
+		util.Fatalf("failed to start genesis-generator service: %v", err)
 	}
 	// TODO: it takes a moment for the docker daemon to synchronize files
 	time.Sleep(time.Second * 10)
@@ -204,6 +265,9 @@ func newPrysmTestEnvironment() *TestEnvironment {
 }
 
 func newLodestarTestEnvironment() *TestEnvironment {
+	// the lodestar beacon node maps its REST API to 3600
+	shared.BeaconAPI = "localhost:3600"
+
 	clientName := "lodestar"
 	return &TestEnvironment{
 		BeaconChainConfig:  ReadBeaconChainConfig(),
@@ -0,0 +1,107 @@
+package ctrl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	conditionStarted               = "service_started"
+	conditionHealthy               = "service_healthy"
+	conditionCompletedSuccessfully = "service_completed_successfully"
+)
+
+// composeFile is the part of docker-compose.yml needed to start services in dependency order
+type composeFile struct {
+	Services map[string]composeService `yaml:"services"`
+}
+
+type composeService struct {
+	DependsOn dependsOn `yaml:"depends_on"`
+}
+
+// dependsOn maps each dependency of a service to the condition it must meet before the service starts
+type dependsOn map[string]string
+
+// UnmarshalYAML accepts both the short list syntax and the long syntax with conditions
+func (d *dependsOn) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*d = make(dependsOn)
+	var short []string
+	if err := unmarshal(&short); err == nil {
+		for _, svc := range short {
+			(*d)[svc] = conditionStarted
+		}
+		return nil
+	}
+	var long map[string]struct {
+		Condition string `yaml:"condition"`
+	}
+	if err := unmarshal(&long); err != nil {
+		return err
+	}
+	for svc, dep := range long {
+		if dep.Condition == "" {
+			dep.Condition = conditionStarted
+		}
+		(*d)[svc] = dep.Condition
+	}
+	return nil
+}
+
+func readComposeFile(path string) (*composeFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f composeFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid compose file %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// startOrder returns services and all of their dependencies, with every service after its dependencies
+func (f *composeFile) startOrder(services []string) ([]string, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var order []string
+	var visit func(svc string) error
+	visit = func(svc string) error {
+		switch state[svc] {
+		case visiting:
+			return fmt.Errorf("dependency cycle through service %s", svc)
+		case visited:
+			return nil
+		}
+		def, ok := f.Services[svc]
+		if !ok {
+			return fmt.Errorf("unknown service %s", svc)
+		}
+		state[svc] = visiting
+		var deps []string
+		for dep := range def.DependsOn {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[svc] = visited
+		order = append(order, svc)
+		return nil
+	}
+	for _, svc := range services {
+		if err := visit(svc); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,131 @@
+package ctrl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const testComposeFile = `
+services:
+  genesis-generator: {}
+  bootnode:
+    depends_on:
+      genesis-generator:
+        condition: service_completed_successfully
+  geth-1:
+    depends_on: [genesis-generator]
+  beacon-node:
+    depends_on:
+      bootnode:
+        condition: service_started
+      geth-1:
+        condition: service_healthy
+  validator-node:
+    depends_on: [beacon-node, geth-1]
+  cycle-a:
+    depends_on: [cycle-b]
+  cycle-b:
+    depends_on: [cycle-a]
+  broken:
+    depends_on: [missing]
+`
+
+func TestDependsOn(t *testing.T) {
+	var f composeFile
+	if err := yaml.Unmarshal([]byte(testComposeFile), &f); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		service string
+		want    dependsOn
+	}{
+		{"genesis-generator", nil},
+		{"bootnode", dependsOn{"genesis-generator": conditionCompletedSuccessfully}},
+		{"geth-1", dependsOn{"genesis-generator": conditionStarted}},
+		{"beacon-node", dependsOn{"bootnode": conditionStarted, "geth-1": conditionHealthy}},
+	}
+	for _, tt := range tests {
+		if got := f.Services[tt.service].DependsOn; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s depends on %v, want %v", tt.service, got, tt.want)
+		}
+	}
+}
+
+func TestStartOrder(t *testing.T) {
+	var f composeFile
+	if err := yaml.Unmarshal([]byte(testComposeFile), &f); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		services []string
+		want     []string
+		err      string
+	}{
+		{
+			name:     "no dependencies",
+			services: []string{"genesis-generator"},
+			want:     []string{"genesis-generator"},
+		},
+		{
+			name:     "chain",
+			services: []string{"bootnode"},
+			want:     []string{"genesis-generator", "bootnode"},
+		},
+		{
+			name:     "shared dependencies start once, in name order",
+			services: []string{"validator-node"},
+			want:     []string{"genesis-generator", "bootnode", "geth-1", "beacon-node", "validator-node"},
+		},
+		{
+			name:     "several services",
+			services: []string{"geth-1", "bootnode"},
+			want:     []string{"genesis-generator", "geth-1", "bootnode"},
+		},
+		{
+			name:     "dependency requested after its dependent",
+			services: []string{"beacon-node", "geth-1"},
+			want:     []string{"genesis-generator", "bootnode", "geth-1", "beacon-node"},
+		},
+		{
+			name:     "none",
+			services: nil,
+			want:     nil,
+		},
+		{
+			name:     "unknown service",
+			services: []string{"nope"},
+			err:      "unknown service nope",
+		},
+		{
+			name:     "unknown dependency",
+			services: []string{"broken"},
+			err:      "unknown service missing",
+		},
+		{
+			name:     "cycle",
+			services: []string{"cycle-a"},
+			err:      "dependency cycle through service cycle-a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.startOrder(tt.services)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,58 +1,23 @@
 package ctrl
 
 import (
-	"fmt"
-	"log"
-	"os"
-	"os/exec"
-	"strings"
-	"sync"
+	"context"
 )
 
-func Run(cmd *exec.Cmd) error {
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Start(); err != nil {
-		log.Fatalf("cmd.Start: %v", err)
-	}
-	if err := cmd.Wait(); err != nil {
-		if exiterr, ok := err.(*exec.ExitError); ok {
-			return exiterr
-		} else {
-			log.Fatalf("cmd.Wait: %v", err)
-		}
-	}
-	return nil
-}
-
-// guards against concurrent access to the docker daemon
-var dockerMutex sync.Mutex
-
+// StartServices starts svcs and their dependencies with the default Orchestrator
 func StartServices(svcs ...string) error {
-	dockerMutex.Lock()
-	defer dockerMutex.Unlock()
-
-	svcArg := strings.Join(svcs, " ")
-	log.Printf("starting services %s", svcArg)
-	err := Run(exec.Command("/bin/sh", "-c", fmt.Sprintf("docker compose up -d %s", svcArg)))
-	if err != nil && err.(*exec.ExitError).ExitCode() == 127 {
-		err = Run(exec.Command("/bin/sh", "-c", fmt.Sprintf("docker compose up -d %s", svcArg)))
-	}
-	return err
+	return DefaultOrchestrator().Up(context.Background(), svcs...)
 }
 
 func StopService(svc string) error {
-	err := Run(exec.Command("/bin/sh", "-c", fmt.Sprintf("docker compose stop %s", svc)))
-	if err != nil && err.(*exec.ExitError).ExitCode() == 127 {
-		err = Run(exec.Command("/bin/sh", "-c", fmt.Sprintf("docker compose stop %s", svc)))
-	}
-	return err
+	return DefaultOrchestrator().Stop(context.Background(), svc)
 }
 
+// StopDevnet removes the containers and volumes of every service, or stops the processes and removes their data
+// dirs when running on the process backend
 func StopDevnet() error {
-	err := Run(exec.Command("/bin/sh", "-c", "docker compose down -v"))
-	if err != nil && err.(*exec.ExitError).ExitCode() == 127 {
-		err = Run(exec.Command("/bin/sh", "-c", "docker compose down -v"))
+	if p := ProcessBackend(); p != nil {
+		return p.Down(context.Background())
 	}
-	return err
+	return DefaultOrchestrator().Down(context.Background())
 }
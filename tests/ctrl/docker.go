@@ -0,0 +1,532 @@
+package ctrl
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Inphi/eip4844-interop/shared"
+)
+
+const (
+	defaultDockerHost = "unix:///var/run/docker.sock"
+
+	labelProject = "com.docker.compose.project"
+	labelService = "com.docker.compose.service"
+	labelOneOff  = "com.docker.compose.oneoff"
+
+	stopTimeoutSeconds = 10
+	pollInterval       = 500 * time.Millisecond
+)
+
+var invalidProjectChars = regexp.MustCompile("[^a-z0-9_-]")
+
+// EngineError is an error response of the Docker Engine API
+type EngineError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *EngineError) Error() string {
+	return fmt.Sprintf("docker engine: %d %s", e.StatusCode, e.Message)
+}
+
+// CommandError is returned when an external command, like the docker CLI or a client binary, fails
+type CommandError struct {
+	Args     []string
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%s: exit code %d: %s", strings.Join(e.Args, " "), e.ExitCode, e.Stderr)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// DockerEngine is an Orchestrator for the services of docker-compose.yml that talks to the Docker Engine API. It finds
+// containers through the labels set by compose. Missing containers are created with `docker compose create`, as
+// turning the compose file into container configs is left to compose.
+type DockerEngine struct {
+	// Project is the compose project name
+	Project string
+	// Dir is the directory holding docker-compose.yml
+	Dir string
+
+	client  *http.Client
+	baseURL string
+
+	// serialises changes to the containers
+	mu      sync.Mutex
+	compose *composeFile
+	// the networks of the containers disconnected by Disconnect, by container ID
+	detached map[string][]networkEndpoint
+	// the interfaces configured by SetNetem, by container ID
+	netem map[string]string
+}
+
+// NewDockerEngine returns a DockerEngine for the devnet in the interop base dir. It connects to DOCKER_HOST, or to the
+// default unix socket, and uses COMPOSE_PROJECT_NAME or the base dir name as the project name like compose does.
+func NewDockerEngine() (*DockerEngine, error) {
+	dir := shared.GetBaseDir()
+	project := os.Getenv("COMPOSE_PROJECT_NAME")
+	if project == "" {
+		project = invalidProjectChars.ReplaceAllString(strings.ToLower(filepath.Base(dir)), "")
+	}
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = defaultDockerHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid DOCKER_HOST %q", err, host)
+	}
+	e := &DockerEngine{Project: project, Dir: dir}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		e.baseURL = "http://docker"
+		e.client = &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}}
+	case "tcp", "http":
+		e.baseURL = "http://" + u.Host
+		e.client = &http.Client{}
+	default:
+		return nil, fmt.Errorf("unsupported DOCKER_HOST %q", host)
+	}
+	return e, nil
+}
+
+func (e *DockerEngine) Up(ctx context.Context, services ...string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	op := strings.Join(services, " ")
+	f, err := e.composeFile()
+	if err != nil {
+		return &ServiceError{Service: op, Op: "up", Err: err}
+	}
+	order, err := f.startOrder(services)
+	if err != nil {
+		return &ServiceError{Service: op, Op: "up", Err: err}
+	}
+	log.Printf("starting services %s", op)
+
+	var missing []string
+	for _, svc := range order {
+		ids, err := e.containers(ctx, svc)
+		if err != nil {
+			return &ServiceError{Service: svc, Op: "up", Err: err}
+		}
+		if len(ids) == 0 {
+			missing = append(missing, svc)
+		}
+	}
+	if len(missing) != 0 {
+		if err := e.create(ctx, missing); err != nil {
+			return &ServiceError{Service: strings.Join(missing, " "), Op: "create", Err: err}
+		}
+	}
+
+	requested := make(map[string]bool)
+	for _, svc := range services {
+		requested[svc] = true
+	}
+	for _, svc := range order {
+		for dep, condition := range f.Services[svc].DependsOn {
+			if err := e.waitCondition(ctx, dep, condition); err != nil {
+				return &ServiceError{Service: svc, Op: "up", Err: err}
+			}
+		}
+		if !requested[svc] && e.completed(ctx, f, svc) {
+			// don't run one-off dependencies like the genesis generator again
+			continue
+		}
+		if err := e.start(ctx, svc); err != nil {
+			return &ServiceError{Service: svc, Op: "start", Err: err}
+		}
+	}
+	return nil
+}
+
+func (e *DockerEngine) Stop(ctx context.Context, services ...string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, svc := range services {
+		ids, err := e.containers(ctx, svc)
+		if err != nil {
+			return &ServiceError{Service: svc, Op: "stop", Err: err}
+		}
+		for _, id := range ids {
+			q := url.Values{"t": {fmt.Sprint(stopTimeoutSeconds)}}
+			if err := e.do(ctx, http.MethodPost, "/containers/"+id+"/stop", q, nil); err != nil {
+				return &ServiceError{Service: svc, Op: "stop", Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+func (e *DockerEngine) Down(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	filters := e.filters()
+	var containers []struct {
+		ID string `json:"Id"`
+	}
+	if err := e.do(ctx, http.MethodGet, "/containers/json", url.Values{"all": {"1"}, "filters": {filters}}, &containers); err != nil {
+		return &ServiceError{Service: e.Project, Op: "down", Err: err}
+	}
+	for _, c := range containers {
+		if err := e.do(ctx, http.MethodDelete, "/containers/"+c.ID, url.Values{"force": {"1"}, "v": {"1"}}, nil); err != nil {
+			return &ServiceError{Service: e.Project, Op: "down", Err: err}
+		}
+	}
+
+	var volumes struct {
+		Volumes []struct {
+			Name string
+		}
+	}
+	if err := e.do(ctx, http.MethodGet, "/volumes", url.Values{"filters": {filters}}, &volumes); err != nil {
+		return &ServiceError{Service: e.Project, Op: "down", Err: err}
+	}
+	for _, v := range volumes.Volumes {
+		if err := e.do(ctx, http.MethodDelete, "/volumes/"+v.Name, nil, nil); err != nil {
+			return &ServiceError{Service: e.Project, Op: "down", Err: err}
+		}
+	}
+
+	var networks []struct {
+		ID string `json:"Id"`
+	}
+	if err := e.do(ctx, http.MethodGet, "/networks", url.Values{"filters": {filters}}, &networks); err != nil {
+		return &ServiceError{Service: e.Project, Op: "down", Err: err}
+	}
+	for _, n := range networks {
+		if err := e.do(ctx, http.MethodDelete, "/networks/"+n.ID, nil, nil); err != nil {
+			return &ServiceError{Service: e.Project, Op: "down", Err: err}
+		}
+	}
+	return nil
+}
+
+// Inspect returns the state of the first container of a service
+func (e *DockerEngine) Inspect(ctx context.Context, service string) (*ContainerState, error) {
+	ids, err := e.containers(ctx, service)
+	if err != nil {
+		return nil, &ServiceError{Service: service, Op: "inspect", Err: err}
+	}
+	if len(ids) == 0 {
+		return nil, &ServiceError{Service: service, Op: "inspect", Err: ErrNoContainer}
+	}
+	var c struct {
+		ID    string `json:"Id"`
+		Name  string
+		State struct {
+			Status   string
+			ExitCode int
+			Health   *struct {
+				Status string
+			}
+		}
+	}
+	if err := e.do(ctx, http.MethodGet, "/containers/"+ids[0]+"/json", nil, &c); err != nil {
+		return nil, &ServiceError{Service: service, Op: "inspect", Err: err}
+	}
+	state := &ContainerState{
+		ID:       c.ID,
+		Name:     strings.TrimPrefix(c.Name, "/"),
+		Service:  service,
+		Status:   c.State.Status,
+		ExitCode: c.State.ExitCode,
+	}
+	if c.State.Health != nil {
+		state.Health = c.State.Health.Status
+	}
+	return state, nil
+}
+
+// Logs writes the output of the containers of a service to w
+func (e *DockerEngine) Logs(ctx context.Context, service string, w io.Writer) error {
+	ids, err := e.containers(ctx, service)
+	if err != nil {
+		return &ServiceError{Service: service, Op: "logs", Err: err}
+	}
+	if len(ids) == 0 {
+		return &ServiceError{Service: service, Op: "logs", Err: ErrNoContainer}
+	}
+	for _, id := range ids {
+		if err := e.containerLogs(ctx, id, w); err != nil {
+			return &ServiceError{Service: service, Op: "logs", Err: err}
+		}
+	}
+	return nil
+}
+
+func (e *DockerEngine) containerLogs(ctx context.Context, id string, w io.Writer) error {
+	var c struct {
+		Config struct {
+			Tty bool
+		}
+	}
+	if err := e.do(ctx, http.MethodGet, "/containers/"+id+"/json", nil, &c); err != nil {
+		return err
+	}
+	resp, err := e.send(ctx, http.MethodGet, "/containers/"+id+"/logs", url.Values{"stdout": {"1"}, "stderr": {"1"}, "timestamps": {"1"}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if c.Config.Tty {
+		_, err = io.Copy(w, resp.Body)
+		return err
+	}
+	return demuxLogs(w, resp.Body)
+}
+
+// demuxLogs copies the stdout and stderr frames of a multiplexed log stream to w
+func demuxLogs(w io.Writer, r io.Reader) error {
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if _, err := io.CopyN(w, r, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+}
+
+func (e *DockerEngine) composeFile() (*composeFile, error) {
+	if e.compose == nil {
+		f, err := readComposeFile(filepath.Join(e.Dir, "docker-compose.yml"))
+		if err != nil {
+			return nil, err
+		}
+		e.compose = f
+	}
+	return e.compose, nil
+}
+
+// filters returns the Engine API filter selecting the resources of the project
+func (e *DockerEngine) filters(labels ...string) string {
+	labels = append([]string{labelProject + "=" + e.Project}, labels...)
+	b, _ := json.Marshal(map[string][]string{"label": labels})
+	return string(b)
+}
+
+// containers returns the IDs of the containers of a service, ordered by name
+func (e *DockerEngine) containers(ctx context.Context, service string) ([]string, error) {
+	var list []struct {
+		ID    string `json:"Id"`
+		Names []string
+	}
+	q := url.Values{
+		"all":     {"1"},
+		"filters": {e.filters(labelService+"="+service, labelOneOff+"=False")},
+	}
+	if err := e.do(ctx, http.MethodGet, "/containers/json", q, &list); err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return strings.Join(list[i].Names, ",") < strings.Join(list[j].Names, ",")
+	})
+	var ids []string
+	for _, c := range list {
+		ids = append(ids, c.ID)
+	}
+	return ids, nil
+}
+
+func (e *DockerEngine) start(ctx context.Context, service string) error {
+	ids, err := e.containers(ctx, service)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return ErrNoContainer
+	}
+	for _, id := range ids {
+		if err := e.do(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completed reports whether service only has dependents waiting for it to complete, and it already did
+func (e *DockerEngine) completed(ctx context.Context, f *composeFile, service string) bool {
+	for _, def := range f.Services {
+		if condition, ok := def.DependsOn[service]; ok && condition != conditionCompletedSuccessfully {
+			return false
+		}
+	}
+	state, err := e.Inspect(ctx, service)
+	return err == nil && state.Exited() && state.ExitCode == 0
+}
+
+// waitCondition waits until service meets a depends_on condition
+func (e *DockerEngine) waitCondition(ctx context.Context, service, condition string) error {
+	switch condition {
+	case conditionStarted:
+		return nil
+	case conditionHealthy:
+		return e.wait(ctx, service, func(s *ContainerState) (bool, error) {
+			switch {
+			case s.Health == "healthy":
+				return true, nil
+			case s.Health == "unhealthy":
+				return false, fmt.Errorf("dependency %s is unhealthy", service)
+			case s.Health == "":
+				return false, fmt.Errorf("dependency %s has no healthcheck", service)
+			case s.Exited():
+				return false, fmt.Errorf("dependency %s exited with code %d", service, s.ExitCode)
+			}
+			return false, nil
+		})
+	case conditionCompletedSuccessfully:
+		return e.wait(ctx, service, func(s *ContainerState) (bool, error) {
+			if !s.Exited() {
+				return false, nil
+			}
+			if s.ExitCode != 0 {
+				return false, fmt.Errorf("dependency %s exited with code %d", service, s.ExitCode)
+			}
+			return true, nil
+		})
+	default:
+		return fmt.Errorf("unsupported depends_on condition %q of %s", condition, service)
+	}
+}
+
+// wait polls the state of a service until done returns true or an error
+func (e *DockerEngine) wait(ctx context.Context, service string, done func(*ContainerState) (bool, error)) error {
+	for {
+		state, err := e.Inspect(ctx, service)
+		if err != nil {
+			return err
+		}
+		ok, err := done(state)
+		if err != nil || ok {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// create creates the containers, networks and volumes of services with the compose CLI
+func (e *DockerEngine) create(ctx context.Context, services []string) error {
+	args := append([]string{"docker", "compose", "--project-name", e.Project, "create", "--no-recreate"}, services...)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = e.Dir
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	if err := cmd.Run(); err != nil {
+		return newCommandError(args, stderr.String(), err)
+	}
+	return nil
+}
+
+func newCommandError(args []string, stderr string, err error) *CommandError {
+	cerr := &CommandError{Args: args, ExitCode: -1, Stderr: strings.TrimSpace(stderr), Err: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		cerr.ExitCode = exitErr.ExitCode()
+	}
+	return cerr
+}
+
+// do sends a request to the Engine API and decodes the JSON response into out, if not nil
+func (e *DockerEngine) do(ctx context.Context, method, path string, query url.Values, out interface{}) error {
+	return e.request(ctx, method, path, query, nil, out)
+}
+
+// request is do with in, if not nil, as the JSON request body
+func (e *DockerEngine) request(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	resp, err := e.send(ctx, method, path, query, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// the container was already started or stopped
+	if resp.StatusCode == http.StatusNotModified || out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: invalid docker engine response to %s %s", err, method, path)
+	}
+	return nil
+}
+
+// send sends a request to the Engine API, turning error responses into an EngineError. The caller closes the body.
+func (e *DockerEngine) send(ctx context.Context, method, path string, query url.Values, in interface{}) (*http.Response, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	u := e.baseURL + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: docker engine request %s %s", err, method, path)
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		defer resp.Body.Close()
+		var body struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return nil, &EngineError{StatusCode: resp.StatusCode, Message: body.Message}
+	}
+	return resp, nil
+}
@@ -0,0 +1,230 @@
+package ctrl
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// logFrame is a frame of a multiplexed log stream
+func logFrame(stream byte, payload string) string {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return string(header) + payload
+}
+
+func TestDemuxLogs(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   string
+		err    error
+	}{
+		{
+			name:   "empty",
+			stream: "",
+			want:   "",
+		},
+		{
+			name:   "stdout",
+			stream: logFrame(1, "hello\n"),
+			want:   "hello\n",
+		},
+		{
+			name:   "interleaved stdout and stderr",
+			stream: logFrame(1, "out 1\n") + logFrame(2, "err 1\n") + logFrame(1, "out 2\n"),
+			want:   "out 1\nerr 1\nout 2\n",
+		},
+		{
+			name:   "empty frame",
+			stream: logFrame(1, "") + logFrame(2, "after\n"),
+			want:   "after\n",
+		},
+		{
+			name:   "truncated header",
+			stream: logFrame(1, "ok\n") + logFrame(1, "x")[:5],
+			want:   "ok\n",
+			err:    io.ErrUnexpectedEOF,
+		},
+		{
+			name:   "truncated payload",
+			stream: logFrame(1, "ok\n") + logFrame(2, "cut short")[:12],
+			want:   "ok\ncut ",
+			err:    io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := demuxLogs(&out, strings.NewReader(tt.stream))
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+// fakeEngine serves the parts of the Docker Engine API used by DockerEngine.Up, with one container per service
+type fakeEngine struct {
+	mu         sync.Mutex
+	containers map[string]*fakeContainer
+	started    []string
+}
+
+type fakeContainer struct {
+	Status   string
+	ExitCode int
+	Health   string
+	// exitCode is the code the container exits with once started, or -1 if it keeps running
+	exitCode int
+}
+
+func (e *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/containers/json":
+		var filters map[string][]string
+		_ = json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+		var list []map[string]interface{}
+		for _, label := range filters["label"] {
+			svc := strings.TrimPrefix(label, labelService+"=")
+			if _, ok := e.containers[svc]; ok && svc != label {
+				list = append(list, map[string]interface{}{"Id": svc, "Names": []string{"/" + svc}})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	case len(parts) == 3 && parts[2] == "json":
+		c := e.containers[parts[1]]
+		state := map[string]interface{}{"Status": c.Status, "ExitCode": c.ExitCode}
+		if c.Health != "" {
+			state["Health"] = map[string]string{"Status": c.Health}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Id": parts[1], "Name": "/" + parts[1], "State": state})
+	case len(parts) == 3 && parts[2] == "start":
+		c := e.containers[parts[1]]
+		e.started = append(e.started, parts[1])
+		c.Status = "running"
+		if c.exitCode >= 0 {
+			c.Status, c.ExitCode = "exited", c.exitCode
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestDockerEngineUp(t *testing.T) {
+	var f composeFile
+	if err := yaml.Unmarshal([]byte(`
+services:
+  genesis-generator: {}
+  geth-1:
+    depends_on:
+      genesis-generator:
+        condition: service_completed_successfully
+  beacon-node:
+    depends_on:
+      genesis-generator:
+        condition: service_completed_successfully
+      geth-1:
+        condition: service_healthy
+`), &f); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		services   []string
+		containers map[string]*fakeContainer
+		want       []string
+		err        string
+	}{
+		{
+			name:     "starts dependencies first",
+			services: []string{"beacon-node"},
+			containers: map[string]*fakeContainer{
+				"genesis-generator": {Status: "created", exitCode: 0},
+				"geth-1":            {Status: "created", Health: "healthy", exitCode: -1},
+				"beacon-node":       {Status: "created", exitCode: -1},
+			},
+			want: []string{"genesis-generator", "geth-1", "beacon-node"},
+		},
+		{
+			name:     "skips completed one-off dependencies",
+			services: []string{"beacon-node"},
+			containers: map[string]*fakeContainer{
+				"genesis-generator": {Status: "exited", exitCode: 0},
+				"geth-1":            {Status: "running", Health: "healthy", exitCode: -1},
+				"beacon-node":       {Status: "exited", exitCode: -1},
+			},
+			want: []string{"geth-1", "beacon-node"},
+		},
+		{
+			name:     "reruns one-off services that are requested",
+			services: []string{"genesis-generator"},
+			containers: map[string]*fakeContainer{
+				"genesis-generator": {Status: "exited", exitCode: 0},
+			},
+			want: []string{"genesis-generator"},
+		},
+		{
+			name:     "failed one-off dependency",
+			services: []string{"geth-1"},
+			containers: map[string]*fakeContainer{
+				"genesis-generator": {Status: "created", exitCode: 1},
+				"geth-1":            {Status: "created", exitCode: -1},
+			},
+			want: []string{"genesis-generator"},
+			err:  "dependency genesis-generator exited with code 1",
+		},
+		{
+			name:     "unhealthy dependency",
+			services: []string{"beacon-node"},
+			containers: map[string]*fakeContainer{
+				"genesis-generator": {Status: "exited", exitCode: 0},
+				"geth-1":            {Status: "running", Health: "unhealthy", exitCode: -1},
+				"beacon-node":       {Status: "created", exitCode: -1},
+			},
+			want: []string{"geth-1"},
+			err:  "dependency geth-1 is unhealthy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeEngine{containers: tt.containers}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+			e := &DockerEngine{Project: "test", client: srv.Client(), baseURL: srv.URL, compose: &f}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			err := e.Up(ctx, tt.services...)
+			if tt.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+			if !reflect.DeepEqual(fake.started, tt.want) {
+				t.Errorf("started %v, want %v", fake.started, tt.want)
+			}
+		})
+	}
+}
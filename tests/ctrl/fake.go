@@ -0,0 +1,112 @@
+package ctrl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// FakeOrchestrator is an in-memory Orchestrator for unit tests, installed with SetOrchestrator. Up marks services as
+// running and Stop as exited, without looking at their dependencies.
+type FakeOrchestrator struct {
+	mu sync.Mutex
+	// States holds the container of every service that has one
+	States map[string]*ContainerState
+	// Errors makes an operation fail, keyed by the operation and the service, like "up beacon-node"
+	Errors map[string]error
+	// Output is what Logs writes for each service
+	Output map[string]string
+	// Calls records every operation, like "up beacon-node" or "down"
+	Calls []string
+}
+
+var _ Orchestrator = (*FakeOrchestrator)(nil)
+
+func NewFakeOrchestrator() *FakeOrchestrator {
+	return &FakeOrchestrator{
+		States: make(map[string]*ContainerState),
+		Errors: make(map[string]error),
+		Output: make(map[string]string),
+	}
+}
+
+func (f *FakeOrchestrator) Up(ctx context.Context, services ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, svc := range services {
+		if err := f.call("up", svc); err != nil {
+			return err
+		}
+		state, ok := f.States[svc]
+		if !ok {
+			state = &ContainerState{ID: svc, Name: svc, Service: svc}
+			f.States[svc] = state
+		}
+		state.Status = "running"
+		state.ExitCode = 0
+	}
+	return nil
+}
+
+func (f *FakeOrchestrator) Stop(ctx context.Context, services ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, svc := range services {
+		if err := f.call("stop", svc); err != nil {
+			return err
+		}
+		if state, ok := f.States[svc]; ok {
+			state.Status = "exited"
+		}
+	}
+	return nil
+}
+
+func (f *FakeOrchestrator) Down(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, "down")
+	if err := f.Errors["down"]; err != nil {
+		return err
+	}
+	f.States = make(map[string]*ContainerState)
+	return nil
+}
+
+func (f *FakeOrchestrator) Inspect(ctx context.Context, service string) (*ContainerState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("inspect", service); err != nil {
+		return nil, err
+	}
+	state, ok := f.States[service]
+	if !ok {
+		return nil, &ServiceError{Service: service, Op: "inspect", Err: ErrNoContainer}
+	}
+	s := *state
+	return &s, nil
+}
+
+func (f *FakeOrchestrator) Logs(ctx context.Context, service string, w io.Writer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("logs", service); err != nil {
+		return err
+	}
+	if _, ok := f.States[service]; !ok {
+		return &ServiceError{Service: service, Op: "logs", Err: ErrNoContainer}
+	}
+	_, err := io.WriteString(w, f.Output[service])
+	return err
+}
+
+// call records an operation and returns the error set for it, if any
+func (f *FakeOrchestrator) call(op, service string) error {
+	key := fmt.Sprintf("%s %s", op, service)
+	f.Calls = append(f.Calls, key)
+	if err := f.Errors[key]; err != nil {
+		return &ServiceError{Service: service, Op: op, Err: err}
+	}
+	return nil
+}
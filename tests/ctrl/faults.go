@@ -0,0 +1,246 @@
+package ctrl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FaultInjector disrupts the services of the devnet to test how clients recover
+type FaultInjector interface {
+	// Pause freezes the processes of a service until Unpause
+	Pause(ctx context.Context, service string) error
+	Unpause(ctx context.Context, service string) error
+	// Kill sends signal, SIGKILL if empty, to a service. The service stays down until it is started again.
+	Kill(ctx context.Context, service, signal string) error
+	// Restart stops and starts a service
+	Restart(ctx context.Context, service string) error
+	// Disconnect detaches a service from its networks, cutting it off from every other service and from the host,
+	// until Reconnect
+	Disconnect(ctx context.Context, service string) error
+	Reconnect(ctx context.Context, service string) error
+	// SetNetem adds latency or packet loss to the traffic leaving a service, replacing any earlier settings
+	SetNetem(ctx context.Context, service string, netem Netem) error
+	// ClearNetem removes the settings of SetNetem
+	ClearNetem(ctx context.Context, service string) error
+}
+
+// Netem configures the tc netem qdisc of a network interface
+type Netem struct {
+	// Device is the network interface, eth0 if empty
+	Device string
+	Delay  time.Duration
+	// Jitter varies Delay by up to this much
+	Jitter time.Duration
+	// Loss is the percentage of packets dropped
+	Loss float64
+}
+
+func (n Netem) device() string {
+	if n.Device == "" {
+		return "eth0"
+	}
+	return n.Device
+}
+
+// args returns the tc arguments applying n
+func (n Netem) args() []string {
+	args := []string{"tc", "qdisc", "replace", "dev", n.device(), "root", "netem"}
+	if n.Delay > 0 {
+		args = append(args, "delay", fmt.Sprintf("%dms", n.Delay.Milliseconds()))
+		if n.Jitter > 0 {
+			args = append(args, fmt.Sprintf("%dms", n.Jitter.Milliseconds()))
+		}
+	}
+	if n.Loss > 0 {
+		args = append(args, "loss", fmt.Sprintf("%g%%", n.Loss))
+	}
+	return args
+}
+
+// Faults returns the FaultInjector of the default Orchestrator. Fault injection isn't available on the process
+// backend.
+func Faults() (FaultInjector, error) {
+	if ProcessBackend() != nil {
+		return nil, errors.New("fault injection requires the docker backend")
+	}
+	f, ok := DefaultOrchestrator().(FaultInjector)
+	if !ok {
+		return nil, errors.New("the orchestrator doesn't support fault injection")
+	}
+	return f, nil
+}
+
+func (e *DockerEngine) Pause(ctx context.Context, service string) error {
+	return e.eachContainer(ctx, service, "pause", func(id string) error {
+		return e.do(ctx, http.MethodPost, "/containers/"+id+"/pause", nil, nil)
+	})
+}
+
+func (e *DockerEngine) Unpause(ctx context.Context, service string) error {
+	return e.eachContainer(ctx, service, "unpause", func(id string) error {
+		return e.do(ctx, http.MethodPost, "/containers/"+id+"/unpause", nil, nil)
+	})
+}
+
+func (e *DockerEngine) Kill(ctx context.Context, service, signal string) error {
+	if signal == "" {
+		signal = "SIGKILL"
+	}
+	return e.eachContainer(ctx, service, "kill", func(id string) error {
+		return e.do(ctx, http.MethodPost, "/containers/"+id+"/kill", url.Values{"signal": {signal}}, nil)
+	})
+}
+
+func (e *DockerEngine) Restart(ctx context.Context, service string) error {
+	return e.eachContainer(ctx, service, "restart", func(id string) error {
+		return e.do(ctx, http.MethodPost, "/containers/"+id+"/restart", url.Values{"t": {fmt.Sprint(stopTimeoutSeconds)}}, nil)
+	})
+}
+
+// networkEndpoint is the attachment of a container to a network, kept to reconnect it
+type networkEndpoint struct {
+	NetworkID string
+	Aliases   []string
+}
+
+// Disconnect detaches the containers of a service from their networks. The container may get another IP address
+// when it is reconnected.
+func (e *DockerEngine) Disconnect(ctx context.Context, service string) error {
+	return e.eachContainer(ctx, service, "disconnect", func(id string) error {
+		if _, ok := e.detached[id]; ok {
+			return nil
+		}
+		var c struct {
+			NetworkSettings struct {
+				Networks map[string]networkEndpoint
+			}
+		}
+		if err := e.do(ctx, http.MethodGet, "/containers/"+id+"/json", nil, &c); err != nil {
+			return err
+		}
+		var endpoints []networkEndpoint
+		for _, ep := range c.NetworkSettings.Networks {
+			body := map[string]interface{}{"Container": id, "Force": true}
+			if err := e.request(ctx, http.MethodPost, "/networks/"+ep.NetworkID+"/disconnect", nil, body, nil); err != nil {
+				return err
+			}
+			endpoints = append(endpoints, ep)
+		}
+		if e.detached == nil {
+			e.detached = make(map[string][]networkEndpoint)
+		}
+		e.detached[id] = endpoints
+		return nil
+	})
+}
+
+func (e *DockerEngine) Reconnect(ctx context.Context, service string) error {
+	return e.eachContainer(ctx, service, "reconnect", func(id string) error {
+		for _, ep := range e.detached[id] {
+			body := map[string]interface{}{
+				"Container":      id,
+				"EndpointConfig": map[string]interface{}{"Aliases": ep.Aliases},
+			}
+			if err := e.request(ctx, http.MethodPost, "/networks/"+ep.NetworkID+"/connect", nil, body, nil); err != nil {
+				return err
+			}
+		}
+		delete(e.detached, id)
+		return nil
+	})
+}
+
+// SetNetem runs tc inside the containers of a service, which needs tc to be installed in the image
+func (e *DockerEngine) SetNetem(ctx context.Context, service string, netem Netem) error {
+	return e.eachContainer(ctx, service, "netem", func(id string) error {
+		if _, err := e.exec(ctx, id, netem.args()...); err != nil {
+			return err
+		}
+		if e.netem == nil {
+			e.netem = make(map[string]string)
+		}
+		e.netem[id] = netem.device()
+		return nil
+	})
+}
+
+func (e *DockerEngine) ClearNetem(ctx context.Context, service string) error {
+	return e.eachContainer(ctx, service, "netem", func(id string) error {
+		device, ok := e.netem[id]
+		if !ok {
+			device = Netem{}.device()
+		}
+		_, err := e.exec(ctx, id, "tc", "qdisc", "del", "dev", device, "root")
+		var cerr *CommandError
+		// there is nothing to delete
+		if err != nil && !(errors.As(err, &cerr) && strings.Contains(cerr.Stderr, "No such file or directory")) {
+			return err
+		}
+		delete(e.netem, id)
+		return nil
+	})
+}
+
+// eachContainer calls fn with the ID of every container of a service, holding the lock on the containers
+func (e *DockerEngine) eachContainer(ctx context.Context, service, op string, fn func(id string) error) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ids, err := e.containers(ctx, service)
+	if err != nil {
+		return &ServiceError{Service: service, Op: op, Err: err}
+	}
+	if len(ids) == 0 {
+		return &ServiceError{Service: service, Op: op, Err: ErrNoContainer}
+	}
+	for _, id := range ids {
+		if err := fn(id); err != nil {
+			return &ServiceError{Service: service, Op: op, Err: err}
+		}
+	}
+	return nil
+}
+
+// exec runs a privileged command inside a container, returning its output. It fails with a CommandError if the
+// command exits with a non-zero code.
+func (e *DockerEngine) exec(ctx context.Context, id string, cmd ...string) (string, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	config := map[string]interface{}{
+		"Cmd":          cmd,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		// tc needs CAP_NET_ADMIN, which the containers don't have
+		"Privileged": true,
+	}
+	if err := e.request(ctx, http.MethodPost, "/containers/"+id+"/exec", nil, config, &created); err != nil {
+		return "", err
+	}
+	resp, err := e.send(ctx, http.MethodPost, "/exec/"+created.ID+"/start", nil, map[string]interface{}{"Detach": false, "Tty": false})
+	if err != nil {
+		return "", err
+	}
+	var output bytes.Buffer
+	err = demuxLogs(&output, resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		ExitCode int
+	}
+	if err := e.do(ctx, http.MethodGet, "/exec/"+created.ID+"/json", nil, &result); err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", &CommandError{Args: cmd, ExitCode: result.ExitCode, Stderr: strings.TrimSpace(output.String())}
+	}
+	return output.String(), nil
+}
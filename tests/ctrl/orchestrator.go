@@ -0,0 +1,94 @@
+package ctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/Inphi/eip4844-interop/tests/util"
+)
+
+// Orchestrator manages the containers of the devnet services
+type Orchestrator interface {
+	// Up starts services after their dependencies, creating missing containers and waiting for the depends_on
+	// conditions of the compose file
+	Up(ctx context.Context, services ...string) error
+	// Stop stops the containers of services. Services without a container are ignored.
+	Stop(ctx context.Context, services ...string) error
+	// Down removes the containers, volumes and networks of the devnet
+	Down(ctx context.Context) error
+	// Inspect returns the state of the container of a service
+	Inspect(ctx context.Context, service string) (*ContainerState, error)
+	// Logs writes the output of the container of a service to w
+	Logs(ctx context.Context, service string, w io.Writer) error
+}
+
+// ContainerState is the state of the container running a service
+type ContainerState struct {
+	ID      string
+	Name    string
+	Service string
+	// Status is one of "created", "running", "paused", "restarting", "removing", "exited" or "dead"
+	Status string
+	// Health is "starting", "healthy" or "unhealthy", or empty if the service has no healthcheck
+	Health   string
+	ExitCode int
+}
+
+func (s *ContainerState) Running() bool {
+	return s.Status == "running"
+}
+
+// Exited reports whether the container stopped after it was started
+func (s *ContainerState) Exited() bool {
+	return s.Status == "exited" || s.Status == "dead"
+}
+
+// ErrNoContainer is returned for services that have no container
+var ErrNoContainer = errors.New("no container")
+
+// ServiceError is returned when an operation on the container of a service fails
+type ServiceError struct {
+	Service string
+	Op      string
+	Err     error
+}
+
+func (e *ServiceError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Service, e.Err)
+}
+
+func (e *ServiceError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	orchestratorMu sync.Mutex
+	orchestrator   Orchestrator
+)
+
+// DefaultOrchestrator returns the Orchestrator used by the package. Unless replaced with SetOrchestrator, it is a
+// DockerEngine for the compose file in the interop base dir.
+func DefaultOrchestrator() Orchestrator {
+	orchestratorMu.Lock()
+	if orchestrator == nil {
+		engine, err := NewDockerEngine()
+		if err != nil {
+			orchestratorMu.Unlock()
+			util.Fatalf("unable to create the docker engine client: %v", err)
+		}
+		orchestrator = engine
+	}
+	o := orchestrator
+	orchestratorMu.Unlock()
+	return o
+}
+
+// SetOrchestrator replaces the Orchestrator used by the package, for instance with a mock
+func SetOrchestrator(o Orchestrator) {
+	orchestratorMu.Lock()
+	defer orchestratorMu.Unlock()
+	orchestrator = o
+}
@@ -0,0 +1,227 @@
+package ctrl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// maxProbeBody bounds the response bodies read by probes
+const maxProbeBody = 1 << 20
+
+// Probe checks whether a service is ready, returning the reason if it isn't
+type Probe func(ctx context.Context) error
+
+// StatusOK accepts 200 responses
+func StatusOK(code int) bool {
+	return code == http.StatusOK
+}
+
+// HTTPProbe is ready once a GET of url returns a status accepted by status, and body accepts the response body.
+// status defaults to StatusOK and body may be nil.
+func HTTPProbe(url string, status func(code int) bool, body func([]byte) error) Probe {
+	if status == nil {
+		status = StatusOK
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		code, data, err := probeRequest(req)
+		if err != nil {
+			return err
+		}
+		if !status(code) {
+			return fmt.Errorf("GET %s: unexpected status %d", url, code)
+		}
+		if body != nil {
+			if err := body(data); err != nil {
+				return fmt.Errorf("GET %s: %w", url, err)
+			}
+		}
+		return nil
+	}
+}
+
+// JSONField accepts JSON bodies where the value at path, a list of object keys, is set and accepted by check if not nil
+func JSONField(path []string, check func(json.RawMessage) error) func([]byte) error {
+	return func(body []byte) error {
+		value := json.RawMessage(body)
+		for _, key := range path {
+			var obj map[string]json.RawMessage
+			if err := json.Unmarshal(value, &obj); err != nil {
+				return fmt.Errorf("invalid response: %w", err)
+			}
+			var ok bool
+			if value, ok = obj[key]; !ok || string(value) == "null" {
+				return fmt.Errorf("response has no %s", strings.Join(path, "."))
+			}
+		}
+		if check != nil {
+			return check(value)
+		}
+		return nil
+	}
+}
+
+// BeaconGenesisProbe is ready once the beacon API at api, a host:port, serves the genesis
+func BeaconGenesisProbe(api string) Probe {
+	return HTTPProbe("http://"+api+"/eth/v1/beacon/genesis", StatusOK, JSONField([]string{"data", "genesis_time"}, nil))
+}
+
+// BeaconSyncedProbe is ready once the beacon node serving api reports that it isn't syncing
+func BeaconSyncedProbe(api string) Probe {
+	return HTTPProbe("http://"+api+"/eth/v1/node/syncing", StatusOK, JSONField([]string{"data", "is_syncing"}, func(v json.RawMessage) error {
+		var syncing bool
+		if err := json.Unmarshal(v, &syncing); err != nil {
+			return err
+		}
+		if syncing {
+			return fmt.Errorf("beacon node is syncing")
+		}
+		return nil
+	}))
+}
+
+// PeerCountProbe is ready once the beacon node serving api has at least min connected peers
+func PeerCountProbe(api string, min int) Probe {
+	return HTTPProbe("http://"+api+"/eth/v1/node/peer_count", StatusOK, JSONField([]string{"data", "connected"}, func(v json.RawMessage) error {
+		// the beacon API encodes integers as strings
+		var connected string
+		if err := json.Unmarshal(v, &connected); err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(connected)
+		if err != nil {
+			return fmt.Errorf("invalid peer count %q", connected)
+		}
+		if n < min {
+			return fmt.Errorf("%d peers connected, waiting for %d", n, min)
+		}
+		return nil
+	}))
+}
+
+// ExecutionSyncedProbe is ready once the JSON-RPC endpoint at url answers eth_syncing with false
+func ExecutionSyncedProbe(url string) Probe {
+	return func(ctx context.Context) error {
+		result, err := jsonRPCCall(ctx, url, "", "eth_syncing")
+		if err != nil {
+			return err
+		}
+		var syncing bool
+		if err := json.Unmarshal(result, &syncing); err != nil || syncing {
+			// a sync progress object
+			return fmt.Errorf("%s is syncing", url)
+		}
+		return nil
+	}
+}
+
+// EngineAuthProbe is ready once the engine API at url accepts a JWT signed with the secret in secretFile
+func EngineAuthProbe(url, secretFile string) Probe {
+	return func(ctx context.Context) error {
+		data, err := ioutil.ReadFile(secretFile)
+		if err != nil {
+			return err
+		}
+		secret, err := hexutil.Decode(strings.TrimSpace(string(data)))
+		if err != nil || len(secret) != 32 {
+			return fmt.Errorf("invalid jwt secret in %s", secretFile)
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iat": time.Now().Unix()}).SignedString(secret)
+		if err != nil {
+			return err
+		}
+		_, err = jsonRPCCall(ctx, url, token, "eth_chainId")
+		return err
+	}
+}
+
+// jsonRPCCall calls method without params, authenticating with a bearer token if set
+func jsonRPCCall(ctx context.Context, url, token, method string) (json.RawMessage, error) {
+	reqBody, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": []interface{}{}})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	code, body, err := probeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if code != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: unexpected status %d", method, url, code)
+	}
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("%s %s: invalid response: %w", method, url, err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s %s: error %d: %s", method, url, resp.Error.Code, resp.Error.Message)
+	}
+	return resp.Result, nil
+}
+
+// probeRequest sends req, returning the status code and the body
+func probeRequest(req *http.Request) (int, []byte, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// waitReady loops until every probe of a service succeeds
+func waitReady(ctx context.Context, svcname string, probes []Probe) error {
+	for {
+		err := runProbes(ctx, probes)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %s isn't ready: %v", ctx.Err(), svcname, err)
+		case <-time.After(1 * time.Second):
+			log.Printf("%s: waiting for readiness: %v", svcname, err)
+		}
+	}
+}
+
+func runProbes(ctx context.Context, probes []Probe) error {
+	for _, probe := range probes {
+		if err := probe(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package ctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Inphi/eip4844-interop/shared"
+)
+
+const processStopTimeout = 10 * time.Second
+
+// ProcessConfig configures services that run locally built client binaries as processes instead of containers.
+// The services read the generated configs from shared/generated-configs and the engine API secret from
+// shared/jwtsecret, so the genesis has to be generated shortly before the test.
+type ProcessConfig struct {
+	// Paths to the client binaries
+	Geth           string
+	PrysmBeacon    string
+	PrysmValidator string
+	Lighthouse     string
+	Lodestar       string
+	// KeysDir holds the validator keystores in the layout written by eth2-val-tools, like the genesis generator's
+	// /tmp/validator-output
+	KeysDir string
+	// DataDir holds the data dir, log file and pid file of every service
+	DataDir string
+}
+
+var (
+	processConfigOnce sync.Once
+	processConfig     *ProcessConfig
+)
+
+// ProcessBackend returns the ProcessConfig read from the environment if INTEROP_BACKEND is "process", or nil if the
+// services run in docker.
+//
+//	GETH_BIN, PRYSM_BEACON_BIN, PRYSM_VALIDATOR_BIN, LIGHTHOUSE_BIN, LODESTAR_BIN  client binaries, looked up in PATH by default
+//	INTEROP_KEYS_DIR  validator keystores, required to run validators
+//	INTEROP_DATA_DIR  data dirs and logs, by default eip4844-interop in the temp dir
+func ProcessBackend() *ProcessConfig {
+	processConfigOnce.Do(func() {
+		if os.Getenv("INTEROP_BACKEND") != "process" {
+			return
+		}
+		processConfig = &ProcessConfig{
+			Geth:           getenv("GETH_BIN", "geth"),
+			PrysmBeacon:    getenv("PRYSM_BEACON_BIN", "beacon-chain"),
+			PrysmValidator: getenv("PRYSM_VALIDATOR_BIN", "validator"),
+			Lighthouse:     getenv("LIGHTHOUSE_BIN", "lighthouse"),
+			Lodestar:       getenv("LODESTAR_BIN", "lodestar"),
+			KeysDir:        os.Getenv("INTEROP_KEYS_DIR"),
+			DataDir:        getenv("INTEROP_DATA_DIR", filepath.Join(os.TempDir(), "eip4844-interop")),
+		}
+	})
+	return processConfig
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// checkGeneratedConfigs fails if the generated configs the processes need are missing
+func (c *ProcessConfig) checkGeneratedConfigs() error {
+	for _, path := range []string{
+		shared.GethChainConfigFilepath(),
+		shared.BeaconChainConfigFilepath(),
+		genesisStateFilepath(),
+		jwtSecretFilepath(),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("%w: run the genesis generator first", err)
+		}
+	}
+	return nil
+}
+
+// Down stops the processes started by this or an earlier run, and removes their data dirs
+func (c *ProcessConfig) Down(ctx context.Context) error {
+	pidFiles, err := filepath.Glob(filepath.Join(c.DataDir, "*.pid"))
+	if err != nil {
+		return err
+	}
+	for _, pidFile := range pidFiles {
+		if err := stopPID(ctx, pidFile); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(c.DataDir)
+}
+
+func stopPID(ctx context.Context, pidFile string) error {
+	data, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		return err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("%w: invalid pid file %s", err, pidFile)
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+	if err := p.Signal(os.Interrupt); err != nil {
+		// the process is gone
+		return nil
+	}
+	deadline := time.Now().Add(processStopTimeout)
+	for time.Now().Before(deadline) {
+		if err := p.Signal(syscall.Signal(0)); err != nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	_ = p.Kill()
+	return nil
+}
+
+// processService is a Service running a client binary as a child process
+type processService struct {
+	name    string
+	dataDir string
+	binary  string
+	// args returns the command line once the dependencies of the service are known
+	args func(ctx context.Context) ([]string, error)
+	// init prepares the data dir before every start
+	init    func(ctx context.Context) error
+	probes  []Probe
+	started chan struct{}
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	exited chan struct{}
+	err    error
+}
+
+func (c *ProcessConfig) newService(name, binary string) *processService {
+	return &processService{
+		name:    name,
+		dataDir: filepath.Join(c.DataDir, name),
+		binary:  binary,
+		started: make(chan struct{}),
+	}
+}
+
+func (s *processService) logFile() string {
+	return s.dataDir + ".log"
+}
+
+func (s *processService) pidFile() string {
+	return s.dataDir + ".pid"
+}
+
+func (s *processService) Start(ctx context.Context) error {
+	if err := os.MkdirAll(s.dataDir, 0755); err != nil {
+		return err
+	}
+	if s.init != nil {
+		if err := s.init(ctx); err != nil {
+			return fmt.Errorf("%w: failed to initialize %s", err, s.name)
+		}
+	}
+	args, err := s.args(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: failed to configure %s", err, s.name)
+	}
+	logFile, err := os.OpenFile(s.logFile(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(s.binary, args...)
+	cmd.Dir = s.dataDir
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	log.Printf("starting %s, logging to %s", s.name, s.logFile())
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%w: failed to start %s", err, s.name)
+	}
+	if err := ioutil.WriteFile(s.pidFile(), []byte(strconv.Itoa(cmd.Process.Pid)), 0644); err != nil {
+		_ = cmd.Process.Kill()
+		return err
+	}
+	exited := make(chan struct{})
+	s.mu.Lock()
+	s.cmd = cmd
+	s.exited = exited
+	s.mu.Unlock()
+	go func() {
+		err := cmd.Wait()
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		_ = os.Remove(s.pidFile())
+		close(exited)
+	}()
+
+	readyCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-exited:
+			cancel()
+		case <-readyCtx.Done():
+		}
+	}()
+	if err := waitReady(readyCtx, s.name, s.probes); err != nil {
+		select {
+		case <-exited:
+			return fmt.Errorf("%s exited before it was ready (%v), see %s", s.name, s.exitErr(), s.logFile())
+		default:
+			return err
+		}
+	}
+	markStarted(s.started)
+	return nil
+}
+
+func (s *processService) exitErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *processService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cmd, exited := s.cmd, s.exited
+	s.mu.Unlock()
+	if cmd == nil {
+		return nil
+	}
+	if err := cmd.Process.Signal(os.Interrupt); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(processStopTimeout):
+		log.Printf("%s did not stop after %v, killing it", s.name, processStopTimeout)
+		_ = cmd.Process.Kill()
+		<-exited
+		return nil
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		return ctx.Err()
+	}
+}
+
+func (s *processService) Started() <-chan struct{} {
+	return s.started
+}
+
+func (s *processService) Name() string {
+	return s.name
+}
+
+func (s *processService) Logs(ctx context.Context, w io.Writer) error {
+	f, err := os.Open(s.logFile())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
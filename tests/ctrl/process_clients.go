@@ -0,0 +1,399 @@
+package ctrl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/Inphi/eip4844-interop/tests/util"
+)
+
+const (
+	depositContract     = "0x4242424242424242424242424242424242424242"
+	blockSignerAddress  = "0xa94f5374fce5edbc8e2a8697c15331677e6ebf0b"
+	prysmWalletPassword = "prysm"
+)
+
+// Host ports of the first node of each kind. The nth node uses the port plus n, which matches the port mappings of
+// docker-compose.yml where it has them.
+const (
+	gethHTTPPort        = 8545
+	gethAuthPort        = 8551
+	gethWSPort          = 8645
+	gethP2PPort         = 30303
+	prysmRPCPort        = 4000
+	prysmUDPPort        = 12000
+	beaconP2PPort       = 13000
+	lighthouseP2PPort   = 9000
+	lodestarMetricsPort = 8008
+	prysmValidatorPort  = 7500
+)
+
+func genesisStateFilepath() string {
+	return filepath.Join(generatedConfigDir(), "genesis.ssz")
+}
+
+func jwtSecretFilepath() string {
+	return filepath.Join(shared.GetBaseDir(), "shared", "jwtsecret")
+}
+
+func generatedConfigDir() string {
+	return filepath.Dir(shared.BeaconChainConfigFilepath())
+}
+
+// beaconAPI returns the REST API address of the nth beacon node, 0 being the node with validators and 1 the follower
+func beaconAPI(index int) string {
+	if index == 0 {
+		return shared.BeaconAPI
+	}
+	return shared.BeaconFollowerAPI
+}
+
+func beaconAPIPort(index int) (string, error) {
+	_, port, err := net.SplitHostPort(beaconAPI(index))
+	return port, err
+}
+
+// NewGethNode returns the nth geth node, following the flags of geth/geth.sh
+func (c *ProcessConfig) NewGethNode(index int) Service {
+	s := c.newService(fmt.Sprintf("geth-%d", index+1), c.Geth)
+	s.probes = gethNodeProbes(fmt.Sprintf("http://localhost:%d", gethHTTPPort+index), fmt.Sprintf("http://localhost:%d", gethAuthPort+index))
+	password := filepath.Join(s.dataDir, "password")
+	s.init = func(ctx context.Context) error {
+		if _, err := os.Stat(filepath.Join(s.dataDir, "keystore")); os.IsNotExist(err) {
+			keyFile := filepath.Join(s.dataDir, "block-signer-key")
+			if err := ioutil.WriteFile(password, nil, 0600); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(keyFile, []byte(shared.PrivateKey), 0600); err != nil {
+				return err
+			}
+			if err := s.run(ctx, "account", "import", "--datadir", s.dataDir, "--password", password, keyFile); err != nil {
+				return err
+			}
+		}
+		return s.run(ctx, "init", "--datadir", s.dataDir, shared.GethChainConfigFilepath())
+	}
+	s.args = func(context.Context) ([]string, error) {
+		return []string{
+			"--datadir", s.dataDir,
+			"--verbosity", "4",
+			"--networkid", "42424243",
+			"--nodiscover",
+			"--port", fmt.Sprint(gethP2PPort + index),
+			"--nat", "extip:127.0.0.1",
+			"--http",
+			"--http.corsdomain=*",
+			"--http.vhosts=*",
+			"--http.addr=127.0.0.1",
+			fmt.Sprintf("--http.port=%d", gethHTTPPort+index),
+			"--http.api=web3,debug,engine,eth,net,txpool",
+			"--authrpc.addr=127.0.0.1",
+			"--authrpc.vhosts=*",
+			"--authrpc.jwtsecret=" + jwtSecretFilepath(),
+			fmt.Sprintf("--authrpc.port=%d", gethAuthPort+index),
+			"--ws",
+			"--ws.addr=127.0.0.1",
+			fmt.Sprintf("--ws.port=%d", gethWSPort+index),
+			"--ws.origins=*",
+			"--ws.api=debug,eth,txpool,net,engine",
+			"--allow-insecure-unlock",
+			"--password", password,
+			"--syncmode=full",
+			"--unlock", blockSignerAddress,
+			"--mine",
+		}, nil
+	}
+	return s
+}
+
+// NewBeaconNode returns the nth beacon node of a client, following the flags of its run_beacon_node.sh. The node
+// with index 0 drives the validators and is the bootnode of the others.
+func (c *ProcessConfig) NewBeaconNode(clientName string, index int) Service {
+	name := fmt.Sprintf("%s-beacon-node", clientName)
+	if index > 0 {
+		name += "-follower"
+	}
+	executionEndpoint := fmt.Sprintf("http://localhost:%d", gethAuthPort+index)
+	var s *processService
+	switch clientName {
+	case "prysm":
+		s = c.newService(name, c.PrysmBeacon)
+		s.args = func(ctx context.Context) ([]string, error) {
+			port, err := beaconAPIPort(index)
+			if err != nil {
+				return nil, err
+			}
+			args := []string{
+				"--accept-terms-of-use",
+				"--verbosity=debug",
+				"--datadir", s.dataDir,
+				"--force-clear-db",
+				"--genesis-state=" + genesisStateFilepath(),
+				"--execution-endpoint=" + executionEndpoint,
+				"--jwt-secret=" + jwtSecretFilepath(),
+				"--chain-config-file=" + shared.BeaconChainConfigFilepath(),
+				"--contract-deployment-block", "0",
+				"--deposit-contract", depositContract,
+				"--rpc-host", "127.0.0.1",
+				"--rpc-port", fmt.Sprint(prysmRPCPort + index),
+				"--grpc-gateway-host", "127.0.0.1",
+				"--grpc-gateway-port", port,
+				"--enable-debug-rpc-endpoints",
+				"--min-sync-peers", fmt.Sprint(index),
+				"--p2p-local-ip", "127.0.0.1",
+				"--p2p-host-ip", "127.0.0.1",
+				"--p2p-tcp-port", fmt.Sprint(beaconP2PPort + index),
+				"--p2p-udp-port", fmt.Sprint(prysmUDPPort + index),
+				"--suggested-fee-recipient=" + blockSignerAddress,
+				"--subscribe-all-subnets",
+			}
+			bootnode, err := c.bootnode(ctx, index)
+			if err != nil || bootnode == "" {
+				return args, err
+			}
+			return append(args, "--bootstrap-node", bootnode), nil
+		}
+	case "lighthouse":
+		s = c.newService(name, c.Lighthouse)
+		s.args = func(ctx context.Context) ([]string, error) {
+			port, err := beaconAPIPort(index)
+			if err != nil {
+				return nil, err
+			}
+			p2pPort := fmt.Sprint(lighthouseP2PPort + index)
+			args := []string{
+				"--debug-level", "debug",
+				"bn",
+				"--datadir", s.dataDir,
+				"--testnet-dir", generatedConfigDir(),
+				"--enable-private-discovery",
+				"--eth1",
+				"--enr-address", "127.0.0.1",
+				"--enr-udp-port", p2pPort,
+				"--enr-tcp-port", p2pPort,
+				"--port", p2pPort,
+				"--http",
+				"--http-address", "127.0.0.1",
+				"--http-port", port,
+				"--disable-packet-filter",
+				"--target-peers", "5",
+				"--http-allow-sync-stalled",
+				"--execution-endpoint", executionEndpoint,
+				"--execution-jwt", jwtSecretFilepath(),
+			}
+			bootnode, err := c.bootnode(ctx, index)
+			if err != nil || bootnode == "" {
+				return args, err
+			}
+			return append(args, "--boot-nodes", bootnode), nil
+		}
+	case "lodestar":
+		s = c.newService(name, c.Lodestar)
+		s.args = func(ctx context.Context) ([]string, error) {
+			port, err := beaconAPIPort(index)
+			if err != nil {
+				return nil, err
+			}
+			args := []string{
+				"beacon",
+				"--logLevel", "verbose",
+				"--paramsFile", shared.BeaconChainConfigFilepath(),
+				"--genesisStateFile", genesisStateFilepath(),
+				"--dataDir", s.dataDir,
+				"--jwt-secret", jwtSecretFilepath(),
+				"--execution.urls", executionEndpoint,
+				"--network.connectToDiscv5Bootnodes",
+				"--sync.isSingleNode",
+				"--subscribeAllSubnets", "true",
+				"--enr.ip", "127.0.0.1",
+				"--port", fmt.Sprint(beaconP2PPort + index),
+				"--rest",
+				"--rest.address", "127.0.0.1",
+				"--rest.port", port,
+				"--rest.namespace", "*",
+				"--metrics",
+				"--metrics.port", fmt.Sprint(lodestarMetricsPort + index),
+				"--suggestedFeeRecipient", "0x8A04d14125D0FDCDc742F4A05C051De07232EDa4",
+			}
+			bootnode, err := c.bootnode(ctx, index)
+			if err != nil || bootnode == "" {
+				return args, err
+			}
+			return append(args, "--bootnodes", bootnode), nil
+		}
+	default:
+		util.Fatalf("unknown client %s", clientName)
+	}
+	s.probes = beaconNodeProbes(beaconAPI(index), index)
+	return s
+}
+
+// NewValidatorNode returns the validator client of clientName, attached to the first beacon node and running the
+// keystores of KeysDir
+func (c *ProcessConfig) NewValidatorNode(clientName string) Service {
+	name := fmt.Sprintf("%s-validator-node", clientName)
+	var s *processService
+	switch clientName {
+	case "prysm":
+		s = c.newService(name, c.PrysmValidator)
+		walletDir := filepath.Join(s.dataDir, "wallet")
+		passwordFile := filepath.Join(s.dataDir, "wallet_pass.txt")
+		s.init = func(context.Context) error {
+			if err := c.checkKeysDir(); err != nil {
+				return err
+			}
+			// the same wallet layout as shared/run_genesis_generator.sh creates
+			accounts := filepath.Join(walletDir, "direct", "accounts")
+			if err := copyFile(filepath.Join(c.KeysDir, "prysm", "direct", "accounts", "all-accounts.keystore.json"), filepath.Join(accounts, "all-accounts.keystore.json")); err != nil {
+				return err
+			}
+			if err := copyFile(filepath.Join(c.KeysDir, "prysm", "keymanageropts.json"), filepath.Join(walletDir, "direct", "keymanageropts.json")); err != nil {
+				return err
+			}
+			return ioutil.WriteFile(passwordFile, []byte(prysmWalletPassword), 0600)
+		}
+		s.args = func(context.Context) ([]string, error) {
+			return []string{
+				"--accept-terms-of-use",
+				"--beacon-rpc-provider", fmt.Sprintf("localhost:%d", prysmRPCPort),
+				"--rpc",
+				"--grpc-gateway-host", "127.0.0.1",
+				"--grpc-gateway-port", fmt.Sprint(prysmValidatorPort),
+				"--datadir", s.dataDir,
+				"--force-clear-db",
+				"--chain-config-file=" + shared.BeaconChainConfigFilepath(),
+				"--suggested-fee-recipient", blockSignerAddress,
+				"--wallet-password-file=" + passwordFile,
+				"--wallet-dir=" + walletDir,
+				"--verbosity", "trace",
+			}, nil
+		}
+	case "lighthouse":
+		s = c.newService(name, c.Lighthouse)
+		validatorsDir := filepath.Join(s.dataDir, "validators")
+		secretsDir := filepath.Join(s.dataDir, "secrets")
+		s.init = func(context.Context) error {
+			if err := c.checkKeysDir(); err != nil {
+				return err
+			}
+			// lighthouse writes its validator definitions next to the keystores
+			if err := copyDir(filepath.Join(c.KeysDir, "keys"), validatorsDir); err != nil {
+				return err
+			}
+			return copyDir(filepath.Join(c.KeysDir, "secrets"), secretsDir)
+		}
+		s.args = func(context.Context) ([]string, error) {
+			return []string{
+				"--debug-level", "info",
+				"vc",
+				"--validators-dir", validatorsDir,
+				"--secrets-dir", secretsDir,
+				"--testnet-dir", generatedConfigDir(),
+				"--init-slashing-protection",
+				"--beacon-nodes", "http://" + beaconAPI(0),
+				"--suggested-fee-recipient", "0x690B9A9E9aa1C9dB991C7721a92d351Db4FaC990",
+			}, nil
+		}
+	case "lodestar":
+		s = c.newService(name, c.Lodestar)
+		s.init = func(context.Context) error {
+			return c.checkKeysDir()
+		}
+		s.args = func(context.Context) ([]string, error) {
+			return []string{
+				"validator",
+				"--paramsFile", shared.BeaconChainConfigFilepath(),
+				"--force",
+				"--dataDir", s.dataDir,
+				"--keystoresDir", filepath.Join(c.KeysDir, "keys"),
+				"--secretsDir", filepath.Join(c.KeysDir, "lodestar-secrets"),
+				"--server", "http://" + beaconAPI(0),
+				"--suggestedFeeRecipient", blockSignerAddress,
+			}, nil
+		}
+	default:
+		util.Fatalf("unknown client %s", clientName)
+	}
+	return s
+}
+
+func (c *ProcessConfig) checkKeysDir() error {
+	if c.KeysDir == "" {
+		return errors.New("INTEROP_KEYS_DIR must point to the validator keystores to run a validator")
+	}
+	return nil
+}
+
+// bootnode returns the ENR of the first beacon node for the others to bootstrap from, waiting for it to come up
+func (c *ProcessConfig) bootnode(ctx context.Context, index int) (string, error) {
+	if index == 0 {
+		return "", nil
+	}
+	api := "http://" + beaconAPI(0)
+	for {
+		enr, err := shared.GetBeaconENR(api)
+		if err == nil {
+			return enr, nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("%w: waiting for the ENR of %s", ctx.Err(), api)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// run runs the binary of the service with args to completion, appending its output to the service log
+func (s *processService) run(ctx context.Context, args ...string) error {
+	logFile, err := os.OpenFile(s.logFile(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.binary, args...)
+	cmd.Dir = s.dataDir
+	cmd.Stdout = logFile
+	cmd.Stderr = io.MultiWriter(logFile, &stderr)
+	if err := cmd.Run(); err != nil {
+		return newCommandError(append([]string{s.binary}, args...), stderr.String(), err)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, data, 0600)
+}
+
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		return copyFile(path, filepath.Join(dst, rel))
+	})
+}
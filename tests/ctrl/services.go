@@ -3,9 +3,8 @@ package ctrl
 import (
 	"context"
 	"fmt"
-	"log"
-	"net/http"
-	"time"
+	"io"
+	"path/filepath"
 
 	"github.com/Inphi/eip4844-interop/shared"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -13,23 +12,56 @@ import (
 )
 
 type Service interface {
+	Name() string
 	Start(ctx context.Context) error
 	Stop(ctx context.Context) error
 	Started() <-chan struct{}
+	// Logs writes the output of the service to w
+	Logs(ctx context.Context, w io.Writer) error
 }
 
 func NewBeaconNode(clientName string) Service {
-	url := fmt.Sprintf("http://%s/eth/v1/beacon/genesis", shared.BeaconAPI)
-	return newDockerService(fmt.Sprintf("%s-beacon-node", clientName), url)
+	if p := ProcessBackend(); p != nil {
+		return p.NewBeaconNode(clientName, 0)
+	}
+	return newDockerService(fmt.Sprintf("%s-beacon-node", clientName), beaconNodeProbes(shared.BeaconAPI, 0)...)
 }
 
 func NewValidatorNode(clientName string) Service {
-	return newDockerService(fmt.Sprintf("%s-validator-node", clientName), "")
+	if p := ProcessBackend(); p != nil {
+		return p.NewValidatorNode(clientName)
+	}
+	return newDockerService(fmt.Sprintf("%s-validator-node", clientName))
 }
 
 func NewBeaconNodeFollower(clientName string) Service {
-	url := fmt.Sprintf("http://%s/eth/v1/beacon/genesis", shared.BeaconFollowerAPI)
-	return newDockerService(fmt.Sprintf("%s-beacon-node-follower", clientName), url)
+	if p := ProcessBackend(); p != nil {
+		return p.NewBeaconNode(clientName, 1)
+	}
+	return newDockerService(fmt.Sprintf("%s-beacon-node-follower", clientName), beaconNodeProbes(shared.BeaconFollowerAPI, 1)...)
+}
+
+// beaconNodeProbes checks that the beacon node serving api has a genesis and at least minPeers peers
+func beaconNodeProbes(api string, minPeers int) []Probe {
+	probes := []Probe{BeaconGenesisProbe(api)}
+	if minPeers > 0 {
+		probes = append(probes, PeerCountProbe(api, minPeers))
+	}
+	return probes
+}
+
+// gethNodeProbes checks that geth serves JSON-RPC at rpc, and accepts the devnet JWT secret on the engine API
+func gethNodeProbes(rpc, engineRPC string) []Probe {
+	return []Probe{ExecutionSyncedProbe(rpc), EngineAuthProbe(engineRPC, engineJWTSecretFilepath())}
+}
+
+// engineJWTSecretFilepath returns the engine API secret shared by the execution and beacon nodes
+func engineJWTSecretFilepath() string {
+	if ProcessBackend() != nil {
+		return jwtSecretFilepath()
+	}
+	// written by the genesis generator
+	return filepath.Join(shared.GetBaseDir(), "shared", "generated-configs", "el", "jwtsecret")
 }
 
 func GetBeaconNodeClient(ctx context.Context) (*beacon.Client, error) {
@@ -49,11 +81,17 @@ func GetBeaconNodeFollowerClient(ctx context.Context) (*beacon.Client, error) {
 }
 
 func NewGethNode() Service {
-	return newDockerService("geth-1", shared.GethRPC)
+	if p := ProcessBackend(); p != nil {
+		return p.NewGethNode(0)
+	}
+	return newDockerService("geth-1", gethNodeProbes(shared.GethRPC, shared.GethEngineRPC)...)
 }
 
 func NewGethNode2() Service {
-	return newDockerService("geth-2", shared.GethRPC)
+	if p := ProcessBackend(); p != nil {
+		return p.NewGethNode(1)
+	}
+	return newDockerService("geth-2", gethNodeProbes(shared.GethRPC2, shared.GethEngineRPC2)...)
 }
 
 func GetExecutionClient(ctx context.Context) (*ethclient.Client, error) {
@@ -65,45 +103,38 @@ func GetExecutionClient(ctx context.Context) (*ethclient.Client, error) {
 }
 
 type dockerService struct {
-	started   chan struct{}
-	svcname   string
-	statusURL string
+	started chan struct{}
+	svcname string
+	probes  []Probe
 }
 
 func (s *dockerService) Start(ctx context.Context) error {
-	if err := StartServices(s.svcname); err != nil {
+	if err := DefaultOrchestrator().Up(ctx, s.svcname); err != nil {
 		return err
 	}
-	if s.statusURL == "" {
-		return nil
-	}
-	req, err := http.NewRequestWithContext(ctx, "GET", s.statusURL, nil)
-	if err != nil {
+	if err := waitReady(ctx, s.svcname, s.probes); err != nil {
 		return err
 	}
-	// loop until the status request returns successfully
-	for {
-		if _, err := http.DefaultClient.Do(req); err == nil {
-			close(s.started)
-			return nil
-		}
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(1 * time.Second):
-			log.Printf("%s: waiting for a successful status check at %s", s.svcname, s.statusURL)
-		}
-	}
+	markStarted(s.started)
+	return nil
 }
 
 func (s *dockerService) Stop(ctx context.Context) error {
-	return StopService(s.svcname)
+	return DefaultOrchestrator().Stop(ctx, s.svcname)
 }
 
 func (s *dockerService) Started() <-chan struct{} {
 	return s.started
 }
 
+func (s *dockerService) Name() string {
+	return s.svcname
+}
+
+func (s *dockerService) Logs(ctx context.Context, w io.Writer) error {
+	return DefaultOrchestrator().Logs(ctx, s.svcname, w)
+}
+
 func ServiceReady(ctx context.Context, svc Service) error {
 	for {
 		select {
@@ -115,10 +146,19 @@ func ServiceReady(ctx context.Context, svc Service) error {
 	}
 }
 
-func newDockerService(svcname string, statusURL string) Service {
+// markStarted closes started unless the service was already started before
+func markStarted(started chan struct{}) {
+	select {
+	case <-started:
+	default:
+		close(started)
+	}
+}
+
+func newDockerService(svcname string, probes ...Probe) Service {
 	return &dockerService{
-		started:   make(chan struct{}),
-		svcname:   svcname,
-		statusURL: statusURL,
+		started: make(chan struct{}),
+		svcname: svcname,
+		probes:  probes,
 	}
 }
@@ -0,0 +1,79 @@
+package ctrl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDockerService(t *testing.T) {
+	fake := NewFakeOrchestrator()
+	fake.Output["beacon-node"] = "beacon logs\n"
+	SetOrchestrator(fake)
+	defer SetOrchestrator(nil)
+
+	ctx := context.Background()
+	probed := 0
+	svc := newDockerService("beacon-node", func(ctx context.Context) error {
+		probed++
+		return nil
+	})
+	if err := svc.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-svc.Started():
+	default:
+		t.Fatal("service isn't marked as started")
+	}
+	if probed != 1 {
+		t.Errorf("probe ran %d times, want 1", probed)
+	}
+	state, err := fake.Inspect(ctx, "beacon-node")
+	if err != nil || !state.Running() {
+		t.Fatalf("got state %+v, %v, want running", state, err)
+	}
+
+	var logs bytes.Buffer
+	if err := svc.Logs(ctx, &logs); err != nil {
+		t.Fatal(err)
+	}
+	if logs.String() != "beacon logs\n" {
+		t.Errorf("got logs %q", logs.String())
+	}
+	if err := svc.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := fake.Inspect(ctx, "beacon-node"); !state.Exited() {
+		t.Errorf("got status %s, want exited", state.Status)
+	}
+
+	want := []string{"up beacon-node", "inspect beacon-node", "logs beacon-node", "stop beacon-node", "inspect beacon-node"}
+	if !reflect.DeepEqual(fake.Calls, want) {
+		t.Errorf("got calls %v, want %v", fake.Calls, want)
+	}
+}
+
+func TestDockerServiceStartError(t *testing.T) {
+	fake := NewFakeOrchestrator()
+	fake.Errors["up geth-1"] = errors.New("boom")
+	SetOrchestrator(fake)
+	defer SetOrchestrator(nil)
+
+	svc := newDockerService("geth-1")
+	err := svc.Start(context.Background())
+	var serr *ServiceError
+	if !errors.As(err, &serr) || serr.Service != "geth-1" || serr.Op != "up" {
+		t.Fatalf("got error %v, want a ServiceError for up geth-1", err)
+	}
+	select {
+	case <-svc.Started():
+		t.Fatal("service is marked as started")
+	default:
+	}
+	if _, err := fake.Inspect(context.Background(), "geth-1"); !errors.Is(err, ErrNoContainer) {
+		t.Errorf("got error %v, want ErrNoContainer", err)
+	}
+}
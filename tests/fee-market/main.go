@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/Inphi/eip4844-interop/shared/blobtx"
 	"github.com/Inphi/eip4844-interop/tests/ctrl"
 	"github.com/Inphi/eip4844-interop/tests/util"
 	"github.com/ethereum/go-ethereum/common"
@@ -18,8 +19,6 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/holiman/uint256"
-	"github.com/protolambda/ztyp/view"
 	"github.com/prysmaticlabs/prysm/v3/api/client/beacon"
 	consensustypes "github.com/prysmaticlabs/prysm/v3/consensus-types/primitives"
 )
@@ -47,11 +46,11 @@ func main() {
 
 	ethClient, err := ctrl.GetExecutionClient(ctx)
 	if err != nil {
-		log.Fatalf("unable to get execution client: %v", err)
+		util.Fatalf("unable to get execution client: %v", err)
 	}
 	beaconClient, err := ctrl.GetBeaconNodeClient(ctx)
 	if err != nil {
-		log.Fatalf("unable to get beacon client: %v", err)
+		util.Fatalf("unable to get beacon client: %v", err)
 	}
 
 	blobsData := make([]types.Blobs, 20)
@@ -76,37 +75,46 @@ func main() {
 	log.Printf("checking blob from beacon node")
 	ma, err := shared.GetBeaconMultiAddress()
 	if err != nil {
-		log.Fatalf("unable to get beacon multiaddr: %v", err)
-	}
-	var downloadedData []byte
-	for _, b := range blocks {
-		data := util.DownloadBlobs(ctx, b.Data.Message.Slot, 1, ma)
-		downloadedData = append(downloadedData, data...)
+		util.Fatalf("unable to get beacon multiaddr: %v", err)
 	}
+	firstSlot := blocks[0].Data.Message.Slot
+	slotCount := uint64(blocks[len(blocks)-1].Data.Message.Slot-firstSlot) + 1
+	downloadedData := downloadRange(ctx, ma, firstSlot, slotCount)
 
 	flatBlobs := FlattenBlobs(blobsData)
 
 	if !bytes.Equal(flatBlobs, downloadedData) {
-		log.Fatalf("mismatch %d %v", len(flatBlobs), len(downloadedData))
+		util.Fatalf("mismatch %d %v", len(flatBlobs), len(downloadedData))
 	}
 
 	log.Printf("checking blob from beacon node follower")
 	time.Sleep(time.Second * 2 * time.Duration(env.BeaconChainConfig.SecondsPerSlot)) // wait a bit for sync
 
-	downloadedData = nil
 	maFollower, err := shared.GetBeaconFollowerMultiAddress()
 	if err != nil {
-		log.Fatalf("unable to get beacon multiaddr: %v", err)
-	}
-	for _, b := range blocks {
-		data := util.DownloadBlobs(ctx, b.Data.Message.Slot, 1, maFollower)
-		downloadedData = append(downloadedData, data...)
+		util.Fatalf("unable to get beacon multiaddr: %v", err)
 	}
+	downloadedData = downloadRange(ctx, maFollower, firstSlot, slotCount)
 	if !bytes.Equal(flatBlobs, downloadedData) {
-		log.Fatalf("mismatch %d %v", len(flatBlobs), len(downloadedData))
+		util.Fatalf("mismatch %d %v", len(flatBlobs), len(downloadedData))
 	}
 }
 
+// downloadRange downloads the sidecars of a slot range over a single connection and concatenates their payloads
+func downloadRange(ctx context.Context, beaconMA string, startSlot consensustypes.Slot, count uint64) []byte {
+	src := util.NewP2PSource(ctx, beaconMA)
+
+	var data []byte
+	for _, sc := range util.DownloadSidecars(ctx, src, startSlot, count) {
+		d, err := sc.Data()
+		if err != nil {
+			util.Fatalf("unable to decode sidecar: %v", err)
+		}
+		data = append(data, d...)
+	}
+	return data
+}
+
 func FlattenBlobs(blobsData []types.Blobs) []byte {
 	var out []byte
 	for _, blobs := range blobsData {
@@ -123,68 +131,39 @@ func FlattenBlobs(blobsData []types.Blobs) []byte {
 }
 
 func UploadBlobsAndCheckBlockHeader(ctx context.Context, client *ethclient.Client, chainId *big.Int, blobsData []types.Blobs) {
-	signer := types.NewDankSigner(chainId)
-
 	key, err := crypto.HexToECDSA(shared.PrivateKey)
 	if err != nil {
-		log.Fatalf("Failed to load private key: %v", err)
+		util.Fatalf("Failed to load private key: %v", err)
 	}
 
-	nonce, err := client.PendingNonceAt(ctx, crypto.PubkeyToAddress(key.PublicKey))
+	// Every transaction pushes excess data gas up, so leave room for all of them to be included
+	multiplier := shared.DefaultFeeMultiplier * float64(len(blobsData))
+	fees, err := shared.EstimateBlobTxFees(ctx, client, multiplier)
 	if err != nil {
-		log.Fatalf("Error getting nonce: %v", err)
+		util.Fatalf("Error estimating fees: %v", err)
 	}
-	log.Printf("Nonce: %d", nonce)
 
-	var txs []*types.Transaction
-	for i := range blobsData {
-		blobs := blobsData[i]
-		commitments, versionedHashes, aggregatedProof, err := blobs.ComputeCommitmentsAndAggregatedProof()
-		to := common.HexToAddress("ffb38a7a99e3e2335be83fc74b7faa19d5531243")
-		txData := types.SignedBlobTx{
-			Message: types.BlobTxMessage{
-				ChainID:             view.Uint256View(*uint256.NewInt(chainId.Uint64())),
-				Nonce:               view.Uint64View(nonce + uint64(i)),
-				Gas:                 210000,
-				GasFeeCap:           view.Uint256View(*uint256.NewInt(5000000000)),
-				GasTipCap:           view.Uint256View(*uint256.NewInt(5000000000)),
-				MaxFeePerDataGas:    view.Uint256View(*uint256.NewInt(3000000000)), // needs to be at least the min fee
-				Value:               view.Uint256View(*uint256.NewInt(12345678)),
-				To:                  types.AddressOptionalSSZ{Address: (*types.AddressSSZ)(&to)},
-				BlobVersionedHashes: versionedHashes,
-			},
-		}
-
-		wrapData := types.BlobTxWrapData{
-			BlobKzgs:           commitments,
-			Blobs:              blobs,
-			KzgAggregatedProof: aggregatedProof,
-		}
-		tx := types.NewTx(&txData, types.WithTxWrapData(&wrapData))
-		tx, err = types.SignTx(tx, signer, key)
-		if err != nil {
-			log.Fatalf("Error signing tx: %v", err)
-		}
-		txs = append(txs, tx)
-	}
+	builder := blobtx.NewBuilder(client, blobtx.WithChainID(chainId), blobtx.WithFees(fees))
+	// at most MaxBlobsPerBlock transactions are included per block, so don't treat queued transactions as stuck
+	sender := blobtx.NewSender(builder, blobtx.WithStuckBlocks(uint64(len(blobsData))))
 
-	receipts := make(chan *types.Receipt, len(txs))
+	receipts := make(chan *types.Receipt, len(blobsData))
 	var wg sync.WaitGroup
-	wg.Add(len(txs))
-	for _, tx := range txs {
-		tx := tx
+	wg.Add(len(blobsData))
+	for _, blobs := range blobsData {
+		blobs := blobs
 		go func() {
 			defer wg.Done()
-			err := client.SendTransaction(ctx, tx)
+			tx, err := sender.Send(ctx, key, blobtx.WithBlobs(blobs))
 			if err != nil {
-				log.Fatalf("Error sending tx: %v", err)
+				util.Fatalf("Error sending tx: %v", err)
 			}
 
-			log.Printf("Waiting for transaction (%v) to be included...", tx.Hash())
+			log.Printf("Waiting for transaction (%v, nonce %d) to be included...", tx.Hash(), tx.Nonce())
 
-			receipt, err := shared.WaitForReceipt(ctx, client, tx.Hash())
+			receipt, err := sender.Wait(ctx, tx)
 			if err != nil {
-				log.Fatalf("Error waiting for transaction receipt %v: %v", tx.Hash(), err)
+				util.Fatalf("Error waiting for transaction receipt %v: %v", tx.Hash(), err)
 			}
 			receipts <- receipt
 		}()
@@ -201,11 +180,11 @@ func UploadBlobsAndCheckBlockHeader(ctx context.Context, client *ethclient.Clien
 			blockHash := receipt.BlockHash.Hex()
 			block, err := client.BlockByHash(ctx, common.HexToHash(blockHash))
 			if err != nil {
-				log.Fatalf("Error getting block: %v", err)
+				util.Fatalf("Error getting block: %v", err)
 			}
 			excessDataGas := block.ExcessDataGas()
 			if excessDataGas == nil {
-				log.Fatalf("nil excess_blobs in block header. block_hash=%v", blockHash)
+				util.Fatalf("nil excess_blobs in block header. block_hash=%v", blockHash)
 			}
 			blockNumbers[blocknum] = true
 			blocks = append(blocks, block)
@@ -218,7 +197,7 @@ func UploadBlobsAndCheckBlockHeader(ctx context.Context, client *ethclient.Clien
 	prevExcessDataGas := new(big.Int)
 	parentBlock, err := client.BlockByHash(ctx, blocks[0].ParentHash())
 	if err != nil {
-		log.Fatalf("Error getting block: %v", err)
+		util.Fatalf("Error getting block: %v", err)
 	}
 	if e := parentBlock.ExcessDataGas(); e != nil {
 		prevExcessDataGas.Set(e)
@@ -228,7 +207,7 @@ func UploadBlobsAndCheckBlockHeader(ctx context.Context, client *ethclient.Clien
 		// Assuming each transaction contains a single blob
 		expected := misc.CalcExcessDataGas(prevExcessDataGas, len(block.Transactions()))
 		if expected.Cmp(block.ExcessDataGas()) != 0 {
-			log.Fatalf("unexpected excess_data_gas field in header. expected %v. got %v", expected, block.ExcessDataGas())
+			util.Fatalf("unexpected excess_data_gas field in header. expected %v. got %v", expected, block.ExcessDataGas())
 		}
 		prevExcessDataGas = expected
 	}
@@ -246,7 +225,7 @@ func FindBlocksWithBlobs(ctx context.Context, client *beacon.Client, startSlot c
 
 		block, err := util.GetBlock(ctx, client, beacon.IdFromSlot(slot))
 		if err != nil {
-			log.Fatalf("Failed to GetBlock: %v", err)
+			util.Fatalf("Failed to GetBlock: %v", err)
 		}
 
 		if len(block.Data.Message.Body.BlobKzgCommitments) != 0 {
@@ -257,7 +236,7 @@ func FindBlocksWithBlobs(ctx context.Context, client *beacon.Client, startSlot c
 	}
 
 	if len(blocks) == 0 {
-		log.Fatalf("Unable to find beacon block containing blobs")
+		util.Fatalf("Unable to find beacon block containing blobs")
 	}
 	return blocks
 }
@@ -8,14 +8,11 @@ import (
 	"time"
 
 	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/Inphi/eip4844-interop/shared/blobtx"
 	"github.com/Inphi/eip4844-interop/tests/ctrl"
 	"github.com/Inphi/eip4844-interop/tests/util"
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/holiman/uint256"
-	"github.com/protolambda/ztyp/view"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -55,18 +52,18 @@ func main() {
 		return env.ValidatorNode.Start(gctx)
 	})
 	if err := g.Wait(); err != nil {
-		log.Fatalf("failed to start services: %v", err)
+		util.Fatalf("failed to start services: %v", err)
 	}
 	ctrl.WaitForShardingFork()
 	ctrl.WaitForEip4844ForkEpoch()
 
 	ethClient, err := ctrl.GetExecutionClient(ctx)
 	if err != nil {
-		log.Fatalf("unable to get execution client: %v", err)
+		util.Fatalf("unable to get execution client: %v", err)
 	}
 	beaconClient, err := ctrl.GetBeaconNodeClient(ctx)
 	if err != nil {
-		log.Fatalf("unable to get beacon client: %v", err)
+		util.Fatalf("unable to get beacon client: %v", err)
 	}
 
 	// Retrieve the current slot to being our blobs search on the beacon chain
@@ -88,23 +85,23 @@ func main() {
 		return env.BeaconNodeFollower.Start(ctx)
 	})
 	if err := g.Wait(); err != nil {
-		log.Fatalf("failed to start services: %v", err)
+		util.Fatalf("failed to start services: %v", err)
 	}
 
 	beaconNodeFollowerClient, err := ctrl.GetBeaconNodeFollowerClient(ctx)
 	if err != nil {
-		log.Fatalf("failed to get beacon node follower client: %v", err)
+		util.Fatalf("failed to get beacon node follower client: %v", err)
 	}
 
 	syncSlot := util.GetHeadSlot(ctx, beaconClient)
 	if err := ctrl.WaitForSlotWithClient(ctx, beaconNodeFollowerClient, syncSlot); err != nil {
-		log.Fatalf("unable to wait for beacon follower sync: %v", err)
+		util.Fatalf("unable to wait for beacon follower sync: %v", err)
 	}
 
 	log.Printf("checking blob from beacon node")
 	beaconMA, err := shared.GetBeaconMultiAddress()
 	if err != nil {
-		log.Fatalf("Unable to get beacon mutliaddress")
+		util.Fatalf("Unable to get beacon mutliaddress")
 	}
 	downloadedData := util.DownloadBlobs(ctx, blobSlot, 1, beaconMA)
 	downloadedBlobs := shared.EncodeBlobs(downloadedData)
@@ -114,7 +111,7 @@ func main() {
 	time.Sleep(time.Second * 2 * time.Duration(env.BeaconChainConfig.SecondsPerSlot)) // wait a bit for sync
 	beaconFollowerMA, err := shared.GetBeaconFollowerMultiAddress()
 	if err != nil {
-		log.Fatalf("Unable to get beacon follower mutliaddress")
+		util.Fatalf("Unable to get beacon follower mutliaddress")
 	}
 	downloadedData = util.DownloadBlobs(ctx, blobSlot, 1, beaconFollowerMA)
 	downloadedBlobs = shared.EncodeBlobs(downloadedData)
@@ -122,54 +119,15 @@ func main() {
 }
 
 func UploadBlobs(ctx context.Context, client *ethclient.Client, chainId *big.Int, blobs types.Blobs) {
-	signer := types.NewDankSigner(chainId)
-
-	key, err := crypto.HexToECDSA(shared.PrivateKey)
-	if err != nil {
-		log.Fatalf("Failed to load private key: %v", err)
-	}
-
-	nonce, err := client.PendingNonceAt(ctx, crypto.PubkeyToAddress(key.PublicKey))
-	if err != nil {
-		log.Fatalf("Error getting nonce: %v", err)
-	}
-	log.Printf("Nonce: %d", nonce)
-
-	commitments, versionedHashes, aggregatedProof, err := blobs.ComputeCommitmentsAndAggregatedProof()
-
-	to := common.HexToAddress("ffb38a7a99e3e2335be83fc74b7faa19d5531243")
-	txData := types.SignedBlobTx{
-		Message: types.BlobTxMessage{
-			ChainID:             view.Uint256View(*uint256.NewInt(chainId.Uint64())),
-			Nonce:               view.Uint64View(nonce),
-			Gas:                 210000,
-			GasFeeCap:           view.Uint256View(*uint256.NewInt(5000000000)),
-			GasTipCap:           view.Uint256View(*uint256.NewInt(5000000000)),
-			MaxFeePerDataGas:    view.Uint256View(*uint256.NewInt(3000000000)), // needs to be at least the min fee
-			Value:               view.Uint256View(*uint256.NewInt(12345678)),
-			To:                  types.AddressOptionalSSZ{Address: (*types.AddressSSZ)(&to)},
-			BlobVersionedHashes: versionedHashes,
-		},
-	}
-
-	wrapData := types.BlobTxWrapData{
-		BlobKzgs:           commitments,
-		Blobs:              blobs,
-		KzgAggregatedProof: aggregatedProof,
-	}
-	tx := types.NewTx(&txData, types.WithTxWrapData(&wrapData))
-	tx, err = types.SignTx(tx, signer, key)
-	if err != nil {
-		log.Fatalf("Error signing tx: %v", err)
-	}
-	err = client.SendTransaction(ctx, tx)
+	builder := blobtx.NewBuilder(client, blobtx.WithChainID(chainId))
+	tx, err := builder.Build(ctx, blobtx.WithBlobs(blobs))
 	if err != nil {
-		log.Fatalf("Error sending tx: %v", err)
+		util.Fatalf("Error building tx: %v", err)
 	}
-	log.Printf("Transaction submitted. hash=%v", tx.Hash())
+	log.Printf("Nonce: %d", tx.Nonce())
 
 	log.Printf("Waiting for transaction (%v) to be included...", tx.Hash())
-	if _, err := shared.WaitForReceipt(ctx, client, tx.Hash()); err != nil {
-		log.Fatalf("Error waiting for transaction receipt %v: %v", tx.Hash(), err)
+	if _, err := builder.SendAndWait(ctx, tx); err != nil {
+		util.Fatalf("Error sending tx %v: %v", tx.Hash(), err)
 	}
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/Inphi/eip4844-interop/shared"
 	"github.com/Inphi/eip4844-interop/tests/ctrl"
+	"github.com/Inphi/eip4844-interop/tests/util"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -34,27 +35,27 @@ func main() {
 
 	client, err := ctrl.GetExecutionClient(ctx)
 	if err != nil {
-		log.Fatalf("unable to get execution client: %v", err)
+		util.Fatalf("unable to get execution client: %v", err)
 	}
 
 	key, err := crypto.HexToECDSA(shared.PrivateKey)
 	if err != nil {
-		log.Fatalf("Failed to load private key: %v", err)
+		util.Fatalf("Failed to load private key: %v", err)
 	}
 
 	nonce, err := client.PendingNonceAt(ctx, crypto.PubkeyToAddress(key.PublicKey))
 	if err != nil {
-		log.Fatalf("Error getting nonce: %v", err)
+		util.Fatalf("Error getting nonce: %v", err)
 	}
 	log.Printf("Nonce: %d", nonce)
 
 	gasTipCap, err := client.SuggestGasTipCap(ctx)
 	if err != nil {
-		log.Fatalf("Suggest gas tip cap: %v", err)
+		util.Fatalf("Suggest gas tip cap: %v", err)
 	}
 	gasFeeCap, err := client.SuggestGasPrice(ctx)
 	if err != nil {
-		log.Fatalf("Suggest gas fee price: %v", err)
+		util.Fatalf("Suggest gas fee price: %v", err)
 	}
 
 	to := common.HexToAddress("ffb38a7a99e3e2335be83fc74b7faa19d5531243")
@@ -67,12 +68,12 @@ func main() {
 		GasFeeCap: gasFeeCap,
 	}), signer, key)
 	if err != nil {
-		log.Fatalf("Error signing tx: %v", err)
+		util.Fatalf("Error signing tx: %v", err)
 	}
 
 	err = client.SendTransaction(ctx, tx)
 	if err != nil {
-		log.Fatalf("Error sending tx: %v", err)
+		util.Fatalf("Error sending tx: %v", err)
 	}
 
 	log.Printf("Waiting for transaction (%v) to be included...", tx.Hash())
@@ -84,7 +85,7 @@ func main() {
 			continue
 		}
 		if err != nil {
-			log.Fatalf("Error getting tx receipt for %v: %v", tx.Hash(), err)
+			util.Fatalf("Error getting tx receipt for %v: %v", tx.Hash(), err)
 		}
 		break
 	}
@@ -92,17 +93,17 @@ func main() {
 	blockHash := receipt.BlockHash.Hex()
 	blk, err := client.BlockByHash(ctx, common.HexToHash(blockHash))
 	if err != nil {
-		log.Fatalf("Error getting block: %v", err)
+		util.Fatalf("Error getting block: %v", err)
 	}
 
 	shardingForkTime := ctrl.GetEnv().GethChainConfig.ShardingForkTime
 	if shardingForkTime == nil {
-		log.Fatalf("shardingForkTime is not set in configuration")
+		util.Fatalf("shardingForkTime is not set in configuration")
 	}
 	eip4844ForkTime := *shardingForkTime
 	if blk.Time() > eip4844ForkTime {
 		// TODO: Avoid this issue by configuring the chain config at runtime
-		log.Fatalf("Test condition violation. Transaction must be included before eip4844 fork. Check the geth chain config")
+		util.Fatalf("Test condition violation. Transaction must be included before eip4844 fork. Check the geth chain config")
 	}
 
 }
@@ -3,345 +3,213 @@ package util
 import (
 	"bytes"
 	"context"
-	"errors"
-	"fmt"
 	"io"
 	"log"
-	"reflect"
-	"runtime/debug"
-	"strings"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/Inphi/eip4844-interop/shared"
-	"github.com/libp2p/go-libp2p"
-	"github.com/libp2p/go-libp2p/core/host"
-	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/Inphi/eip4844-interop/shared/p2pclient"
+	"github.com/Inphi/eip4844-interop/shared/sidecar"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/libp2p/go-libp2p/core/peer"
-	"github.com/libp2p/go-libp2p/core/protocol"
-	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
-	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
-	ma "github.com/multiformats/go-multiaddr"
-	ssz "github.com/prysmaticlabs/fastssz"
-	"github.com/prysmaticlabs/go-bitfield"
-	"github.com/prysmaticlabs/prysm/v3/beacon-chain/p2p"
-	"github.com/prysmaticlabs/prysm/v3/beacon-chain/p2p/encoder"
-	p2ptypes "github.com/prysmaticlabs/prysm/v3/beacon-chain/p2p/types"
-	"github.com/prysmaticlabs/prysm/v3/beacon-chain/sync"
+	"github.com/prysmaticlabs/prysm/v3/api/client/beacon"
 	consensustypes "github.com/prysmaticlabs/prysm/v3/consensus-types/primitives"
-	"github.com/prysmaticlabs/prysm/v3/consensus-types/wrapper"
 	ethpb "github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1"
-	"github.com/prysmaticlabs/prysm/v3/proto/prysm/v1alpha1/metadata"
 )
 
-var responseCodeSuccess = byte(0x00)
+const discoveryTimeout = time.Minute
 
-func SendBlobsSidecarsByRangeRequest(ctx context.Context, h host.Host, encoding encoder.NetworkEncoding, pid peer.ID, req *ethpb.BlobsSidecarsByRangeRequest) ([]*ethpb.BlobsSidecar, error) {
-	topic := fmt.Sprintf("%s%s", p2p.RPCBlobsSidecarsByRangeTopicV1, encoding.ProtocolSuffix())
-
-	stream, err := h.NewStream(ctx, pid, protocol.ID(topic))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		_ = stream.Close()
-	}()
-
-	if _, err := encoding.EncodeWithMaxLength(stream, req); err != nil {
-		_ = stream.Reset()
-		return nil, err
-	}
+var (
+	p2pClientOnce  sync.Once
+	p2pClient      *p2pclient.Client
+	p2pChainConfig *p2pclient.ChainConfig
+)
 
-	if err := stream.CloseWrite(); err != nil {
-		_ = stream.Reset()
-		return nil, err
-	}
+// SetP2PChainConfig sets the chain config used by P2PClient for the Status handshake and to check context bytes.
+// It must be called before P2PClient is first used.
+func SetP2PChainConfig(cfg *p2pclient.ChainConfig) {
+	p2pChainConfig = cfg
+}
 
-	var blobsSidecars []*ethpb.BlobsSidecar
-	for {
-		isFirstChunk := len(blobsSidecars) == 0
-		blobs, err := readChunkedBlobsSidecar(stream, encoding, isFirstChunk)
-		if errors.Is(err, io.EOF) {
-			break
-		}
+// P2PClient returns the libp2p client shared by every download of the test run
+func P2PClient() *p2pclient.Client {
+	p2pClientOnce.Do(func() {
+		var err error
+		p2pClient, err = p2pclient.New(p2pChainConfig)
 		if err != nil {
-			return nil, err
+			Fatalf("failed to create p2p client: %v", err)
 		}
-		blobsSidecars = append(blobsSidecars, blobs)
-	}
-	return blobsSidecars, nil
+	})
+	return p2pClient
 }
 
-func readChunkedBlobsSidecar(stream network.Stream, encoding encoder.NetworkEncoding, isFirstChunk bool) (*ethpb.BlobsSidecar, error) {
-	var (
-		code   uint8
-		errMsg string
-		err    error
-	)
-	if isFirstChunk {
-		code, errMsg, err = sync.ReadStatusCode(stream, encoding)
-	} else {
-		sync.SetStreamReadDeadline(stream, time.Second*10)
-		code, errMsg, err = readStatusCodeNoDeadline(stream, encoding)
-	}
+// NewP2PSource connects P2PClient to the beacon node at beaconMA
+func NewP2PSource(ctx context.Context, beaconMA string) *p2pclient.PeerSource {
+	pid, err := P2PClient().Connect(ctx, beaconMA)
 	if err != nil {
-		return nil, err
-	}
-	if code != 0 {
-		return nil, errors.New(errMsg)
+		Fatalf("failed to connect to %s: %v", beaconMA, err)
 	}
-	// ignored: we assume we got the correct context
-	b := make([]byte, 4)
-	if _, err := stream.Read(b); err != nil {
-		return nil, err
-	}
-	sidecar := new(ethpb.BlobsSidecar)
-	err = encoding.DecodeWithMaxLength(stream, sidecar)
-	return sidecar, err
+	return P2PClient().Source(pid)
 }
 
-func readStatusCodeNoDeadline(stream network.Stream, encoding encoder.NetworkEncoding) (uint8, string, error) {
-	b := make([]byte, 1)
-	_, err := stream.Read(b)
+// DiscoverP2PSource finds the beacon node serving beaconAPI through discv5, starting from the devnet bootnodes,
+// and connects P2PClient to it
+func DiscoverP2PSource(ctx context.Context, beaconAPI string) *p2pclient.PeerSource {
+	id, err := shared.GetBeaconPeerID(beaconAPI)
 	if err != nil {
-		return 0, "", err
-	}
-	if b[0] == responseCodeSuccess {
-		return 0, "", nil
-	}
-	msg := &p2ptypes.ErrorMessage{}
-	if err := encoding.DecodeWithMaxLength(stream, msg); err != nil {
-		return 0, "", err
-	}
-	return b[0], string(*msg), nil
-}
-
-// Using p2p RPC
-func DownloadBlobs(ctx context.Context, startSlot consensustypes.Slot, count uint64, beaconMA string) []byte {
-	log.Print("downloading blobs...")
-
-	req := &ethpb.BlobsSidecarsByRangeRequest{
-		StartSlot: startSlot,
-		Count:     count,
+		Fatalf("unable to get peer id of %s: %v", beaconAPI, err)
 	}
-
-	h, err := libp2p.New(libp2p.Transport(tcp.NewTCPTransport))
+	pid, err := peer.Decode(id)
 	if err != nil {
-		log.Fatalf("failed to create libp2p context: %v", err)
-	}
-	defer func() {
-		_ = h.Close()
-	}()
-	h.RemoveStreamHandler(identify.IDDelta)
-	// setup enough handlers so lighthouse thinks it's dealing with a beacon peer
-	setHandler(h, p2p.RPCPingTopicV1, pingHandler)
-	setHandler(h, p2p.RPCGoodByeTopicV1, pingHandler)
-	setHandler(h, p2p.RPCMetaDataTopicV1, pingHandler)
-	setHandler(h, p2p.RPCMetaDataTopicV2, pingHandler)
-
-	nilHandler := func(ctx context.Context, i interface{}, stream network.Stream) error {
-		log.Printf("received request for %s", stream.Protocol())
-		return nil
+		Fatalf("invalid peer id %s: %v", id, err)
 	}
-	setHandler(h, p2p.RPCBlocksByRangeTopicV1, nilHandler)
-	setHandler(h, p2p.RPCBlocksByRangeTopicV2, nilHandler)
-	setHandler(h, p2p.RPCBlobsSidecarsByRangeTopicV1, nilHandler)
-
-	maddr, err := ma.NewMultiaddr(beaconMA)
+	bootnodes, err := p2pclient.ReadENRFile(shared.BootENRFilepath())
 	if err != nil {
-		log.Fatalf("failed to get multiaddr: %v", err)
+		Fatalf("unable to read bootnodes: %v", err)
 	}
-	addrInfo, err := peer.AddrInfoFromP2pAddr(maddr)
+	discoverCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+	nodes, err := p2pclient.Discover(discoverCtx, bootnodes, p2pclient.PeerIDFilter(pid), 1)
 	if err != nil {
-		log.Fatalf("failed to get addr info: %v", err)
+		Fatalf("unable to discover %s: %v", pid, err)
 	}
-
-	err = h.Connect(ctx, *addrInfo)
+	pid, err = P2PClient().ConnectNode(ctx, nodes[0])
 	if err != nil {
-		log.Fatalf("libp2p host connect: %v", err)
+		Fatalf("failed to connect to %s: %v", nodes[0], err)
 	}
+	return P2PClient().Source(pid)
+}
 
-	sidecars, err := SendBlobsSidecarsByRangeRequest(ctx, h, encoder.SszNetworkEncoder{}, addrInfo.ID, req)
-	if err != nil {
-		log.Fatalf("failed to send blobs p2p request: %v", err)
-	}
+// Using p2p RPC
+func DownloadBlobs(ctx context.Context, startSlot consensustypes.Slot, count uint64, beaconMA string) []byte {
+	src := NewP2PSource(ctx, beaconMA)
+	return DownloadBlobsFrom(ctx, src, startSlot, count)
+}
+
+// DownloadBlobsFrom is like DownloadBlobs, but retrieves the sidecars from src
+func DownloadBlobsFrom(ctx context.Context, src sidecar.BlobSource, startSlot consensustypes.Slot, count uint64) []byte {
+	sidecars := DownloadSidecars(ctx, src, startSlot, count)
 
 	anyBlobs := false
 	blobsBuffer := new(bytes.Buffer)
-	for _, sidecar := range sidecars {
-		if sidecar.Blobs == nil || len(sidecar.Blobs) == 0 {
+	for _, sc := range sidecars {
+		if len(sc.Blobs) == 0 {
 			continue
 		}
 		anyBlobs = true
-		for _, blob := range sidecar.Blobs {
-			data := shared.DecodeFlatBlob(blob.Data)
-			_, _ = blobsBuffer.Write(data)
+		data, err := sc.Data()
+		if err != nil {
+			Fatalf("failed to decode sidecar: %v", err)
 		}
-
-		// stop after the first sidecar with blobs:
-		break
+		_, _ = blobsBuffer.Write(data)
 	}
 	if !anyBlobs {
-		log.Fatalf("No blobs found in requested slots, sidecar count: %d", len(sidecars))
+		Fatalf("No blobs found in requested slots, sidecar count: %d", len(sidecars))
 	}
 
 	return blobsBuffer.Bytes()
 }
 
-func getMultiaddr(ctx context.Context, h host.Host, addr string) (ma.Multiaddr, error) {
-	multiaddr, err := ma.NewMultiaddr(addr)
+// DownloadSidecars returns every sidecar in the count slots starting at startSlot, ordered by slot
+func DownloadSidecars(ctx context.Context, src sidecar.BlobSource, startSlot consensustypes.Slot, count uint64) []*sidecar.Sidecar {
+	log.Printf("downloading sidecars of slots %d-%d from %s source...", startSlot, uint64(startSlot)+count-1, src.Name())
+
+	resp, err := src.SidecarsByRange(ctx, uint64(startSlot), count)
 	if err != nil {
-		return nil, err
+		Fatalf("failed to download sidecars from %s source: %v", src.Name(), err)
 	}
-	_, id := peer.SplitAddr(multiaddr)
-	if id != "" {
-		return multiaddr, nil
-	}
-	// peer ID wasn't provided, look it up
-	id, err = retrievePeerID(ctx, h, addr)
-	if err != nil {
-		return nil, err
+	verifySidecars(ctx, src, resp, false)
+	sidecars := make([]*sidecar.Sidecar, len(resp))
+	for i, sc := range resp {
+		sidecars[i] = sidecar.FromProto(sc)
 	}
-	return ma.NewMultiaddr(fmt.Sprintf("%s/p2p/%s", addr, string(id)))
+	sort.SliceStable(sidecars, func(i, j int) bool { return sidecars[i].Slot < sidecars[j].Slot })
+	return sidecars
 }
 
-// Helper for retrieving the peer ID from a security error... obviously don't use this in production!
-// See https://github.com/libp2p/go-libp2p-noise/blob/v0.3.0/handshake.go#L250
-func retrievePeerID(ctx context.Context, h host.Host, addr string) (peer.ID, error) {
-	incorrectPeerID := "16Uiu2HAmSifdT5QutTsaET8xqjWAMPp4obrQv7LN79f2RMmBe3nY"
-	addrInfo, err := peer.AddrInfoFromString(fmt.Sprintf("%s/p2p/%s", addr, incorrectPeerID))
+// DownloadTransactionBlobs downloads the blobs of a transaction, located through its receipt and the beacon API
+func DownloadTransactionBlobs(ctx context.Context, client *ethclient.Client, beaconAPI string, txHash common.Hash, beaconMA string) []byte {
+	log.Printf("downloading blobs of tx %v...", txHash)
+
+	loc, err := shared.LocateTransactionBlobs(ctx, client, beaconAPI, txHash)
 	if err != nil {
-		return "", err
-	}
-	err = h.Connect(ctx, *addrInfo)
-	if err == nil {
-		return "", errors.New("unexpected successful connection")
+		Fatalf("unable to locate blobs of tx %v: %v", txHash, err)
 	}
-	if strings.Contains(err.Error(), "but remote key matches") {
-		split := strings.Split(err.Error(), " ")
-		return peer.ID(split[len(split)-1]), nil
+	src := NewP2PSource(ctx, beaconMA)
+	sidecars, err := src.SidecarsByRange(ctx, loc.Slot, 1)
+	if err != nil {
+		Fatalf("failed to send blobs p2p request: %v", err)
 	}
-	return "", err
-}
+	verifySidecars(ctx, src, sidecars, false)
 
-type rpcHandler func(context.Context, interface{}, network.Stream) error
-
-// adapted from prysm's handler router
-func setHandler(h host.Host, baseTopic string, handler rpcHandler) {
-	encoding := &encoder.SszNetworkEncoder{}
-	topic := baseTopic + encoding.ProtocolSuffix()
-	h.SetStreamHandler(protocol.ID(topic), func(stream network.Stream) {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("Panic occurred: %v", r)
-				log.Printf("%s", debug.Stack())
-			}
-		}()
-
-		// Resetting after closing is a no-op so defer a reset in case something goes wrong.
-		// It's up to the handler to Close the stream (send an EOF) if
-		// it successfully writes a response. We don't blindly call
-		// Close here because we may have only written a partial
-		// response.
-		defer func() {
-			_err := stream.Reset()
-			_ = _err
-		}()
-
-		base, ok := p2p.RPCTopicMappings[baseTopic]
-		if !ok {
-			log.Printf("ERROR: Could not retrieve base message for topic %s", baseTopic)
-			return
-		}
-		bb := base
-		t := reflect.TypeOf(base)
-		// Copy Base
-		base = reflect.New(t)
-
-		if baseTopic == p2p.RPCMetaDataTopicV1 || baseTopic == p2p.RPCMetaDataTopicV2 {
-			if err := metadataHandler(context.Background(), base, stream); err != nil {
-				if err != p2ptypes.ErrWrongForkDigestVersion {
-					log.Printf("ERROR: Could not handle p2p RPC: %v", err)
-				}
-			}
-			return
+	var blobs [][]byte
+	for _, sc := range sidecars {
+		for _, blob := range sc.Blobs {
+			blobs = append(blobs, blob.Data)
 		}
+	}
+	matched, err := shared.FilterBlobs(blobs, loc.VersionedHashes)
+	if err != nil {
+		Fatalf("blobs of tx %v not found at slot %d: %v", txHash, loc.Slot, err)
+	}
 
-		// Given we have an input argument that can be pointer or the actual object, this gives us
-		// a way to check for its reflect.Kind and based on the result, we can decode
-		// accordingly.
-		if t.Kind() == reflect.Ptr {
-			msg, ok := reflect.New(t.Elem()).Interface().(ssz.Unmarshaler)
-			if !ok {
-				log.Printf("ERROR: message of %T ptr does not support marshaller interface. topic=%s", bb, baseTopic)
-				return
-			}
-			if err := encoding.DecodeWithMaxLength(stream, msg); err != nil {
-				log.Printf("ERROR: could not decode stream message: %v", err)
-				return
-			}
-			if err := handler(context.Background(), msg, stream); err != nil {
-				if err != p2ptypes.ErrWrongForkDigestVersion {
-					log.Printf("ERROR: Could not handle p2p RPC: %v", err)
-				}
-			}
-		} else {
-			nTyp := reflect.New(t)
-			msg, ok := nTyp.Interface().(ssz.Unmarshaler)
-			if !ok {
-				log.Printf("ERROR: message of %T does not support marshaller interface", msg)
-				return
-			}
-			if err := handler(context.Background(), msg, stream); err != nil {
-				if err != p2ptypes.ErrWrongForkDigestVersion {
-					log.Printf("ERROR: Could not handle p2p RPC: %v", err)
-				}
-			}
+	blobsBuffer := new(bytes.Buffer)
+	for i, blob := range matched {
+		if _, err := io.Copy(blobsBuffer, shared.NewBlobReader(bytes.NewReader(blob))); err != nil {
+			Fatalf("failed to decode blob %d of tx %v: %v", i, txHash, err)
 		}
-	})
-}
-
-func dummyMetadata() metadata.Metadata {
-	metaData := &ethpb.MetaDataV1{
-		SeqNumber: 0,
-		Attnets:   bitfield.NewBitvector64(),
-		Syncnets:  bitfield.Bitvector4{byte(0x00)},
 	}
-	return wrapper.WrappedMetadataV1(metaData)
+	return blobsBuffer.Bytes()
 }
 
-// pingHandler reads the incoming ping rpc message from the peer.
-func pingHandler(_ context.Context, _ interface{}, stream network.Stream) error {
-	encoding := &encoder.SszNetworkEncoder{}
-	defer closeStream(stream)
-	if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
-		return err
+// DownloadBlobsByRoot downloads the blobs of the given beacon blocks through the by-root RPC.
+// Unlike by-range requests, the blocks don't need to be canonical.
+func DownloadBlobsByRoot(ctx context.Context, roots [][32]byte, beaconMA string) []byte {
+	log.Print("downloading blobs by root...")
+
+	src := NewP2PSource(ctx, beaconMA)
+	hashes := make([]common.Hash, len(roots))
+	for i, root := range roots {
+		hashes[i] = root
 	}
-	m := dummyMetadata()
-	sq := consensustypes.SSZUint64(m.SequenceNumber())
-	if _, err := encoding.EncodeWithMaxLength(stream, &sq); err != nil {
-		return fmt.Errorf("%w: pingHandler stream write", err)
+	sidecars, err := src.SidecarsByRoot(ctx, hashes)
+	if err != nil {
+		Fatalf("failed to send blobs by root p2p request: %v", err)
 	}
-	return nil
-}
-
-// metadataHandler spoofs a valid looking metadata message
-func metadataHandler(_ context.Context, _ interface{}, stream network.Stream) error {
-	encoding := &encoder.SszNetworkEncoder{}
-	defer closeStream(stream)
-	if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
-		return err
+	if len(sidecars) == 0 {
+		Fatalf("No sidecars found for the requested roots")
 	}
+	verifySidecars(ctx, src, sidecars, true)
 
-	// write a dummy metadata message to satify the client handshake
-	m := dummyMetadata()
-	if _, err := encoding.EncodeWithMaxLength(stream, m); err != nil {
-		return fmt.Errorf("%w: metadata stream write", err)
+	blobsBuffer := new(bytes.Buffer)
+	for _, sc := range sidecars {
+		data, err := sidecar.FromProto(sc).Data()
+		if err != nil {
+			Fatalf("failed to decode sidecar: %v", err)
+		}
+		_, _ = blobsBuffer.Write(data)
 	}
-	return nil
+	return blobsBuffer.Bytes()
 }
 
-func closeStream(stream network.Stream) {
-	if err := stream.Close(); err != nil {
-		log.Println(err)
+// verifySidecars checks sidecars downloaded from src against the blocks served by the beacon node at shared.BeaconAPI.
+// Sidecars requested by root are checked against the block with their root, the others against the canonical block
+// at their slot.
+func verifySidecars(ctx context.Context, src sidecar.BlobSource, sidecars []*ethpb.BlobsSidecar, byRoot bool) {
+	client, err := beacon.NewClient(shared.BeaconAPI)
+	if err != nil {
+		Fatalf("unable to create beacon client: %v", err)
+	}
+	verify := sidecar.VerifyCanonical
+	if byRoot {
+		verify = sidecar.VerifyByRoot
+	}
+	for _, sc := range sidecars {
+		if err := verify(ctx, client, sc); err != nil {
+			Fatalf("invalid sidecar from %s source: %v", src.Name(), err)
+		}
 	}
 }
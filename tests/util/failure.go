@@ -0,0 +1,61 @@
+package util
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// FailureHooksTimeout bounds the time the failure hooks may take before the process exits
+const FailureHooksTimeout = 2 * time.Minute
+
+var (
+	failureMu    sync.Mutex
+	failureHooks []func()
+	failureOnce  sync.Once
+)
+
+// OnFailure registers fn to run when the test fails through Fatalf or Fatal, before the process exits
+func OnFailure(fn func()) {
+	failureMu.Lock()
+	defer failureMu.Unlock()
+	failureHooks = append(failureHooks, fn)
+}
+
+// Fatalf is log.Fatalf, running the failure hooks before exiting
+func Fatalf(format string, v ...interface{}) {
+	_ = log.Output(2, fmt.Sprintf(format, v...))
+	fail()
+}
+
+// Fatal is log.Fatal, running the failure hooks before exiting
+func Fatal(v ...interface{}) {
+	_ = log.Output(2, fmt.Sprint(v...))
+	fail()
+}
+
+// fail runs the failure hooks once and exits. The first caller waits for the hooks for at most FailureHooksTimeout;
+// any later caller, including a hook failing in turn, blocks in failureOnce.Do until the process exits.
+func fail() {
+	failureOnce.Do(func() {
+		failureMu.Lock()
+		hooks := failureHooks
+		failureMu.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := len(hooks) - 1; i >= 0; i-- {
+				hooks[i]()
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(FailureHooksTimeout):
+			log.Printf("failure hooks did not finish within %v", FailureHooksTimeout)
+		}
+		os.Exit(1)
+	})
+}
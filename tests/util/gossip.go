@@ -0,0 +1,60 @@
+package util
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/Inphi/eip4844-interop/shared/p2pclient"
+	consensustypes "github.com/prysmaticlabs/prysm/v3/consensus-types/primitives"
+)
+
+// GossipRecorder keeps the beacon blocks and blobs sidecars received over gossip, by slot
+type GossipRecorder struct {
+	mu     sync.Mutex
+	blocks map[consensustypes.Slot]*p2pclient.GossipBlock
+}
+
+// WatchGossip records the beacon_block_and_blobs_sidecar gossip received from the beacon node at beaconMA until ctx is done
+func WatchGossip(ctx context.Context, beaconMA string) *GossipRecorder {
+	r := &GossipRecorder{blocks: make(map[consensustypes.Slot]*p2pclient.GossipBlock)}
+	if _, err := P2PClient().Gossip(); err != nil {
+		Fatalf("failed to start gossip: %v", err)
+	}
+	if _, err := P2PClient().Connect(ctx, beaconMA); err != nil {
+		Fatalf("failed to connect to %s: %v", beaconMA, err)
+	}
+	go func() {
+		err := P2PClient().WatchBlocksAndBlobs(ctx, func(b *p2pclient.GossipBlock) {
+			log.Printf("gossip: slot=%d blobs=%d latency=%v", b.Slot, b.Blobs, b.Latency)
+			r.mu.Lock()
+			r.blocks[b.Slot] = b
+			r.mu.Unlock()
+		}, func(err error) {
+			Fatalf("invalid gossip message: %v", err)
+		})
+		if err != nil {
+			Fatalf("failed to watch gossip: %v", err)
+		}
+	}()
+	return r
+}
+
+// Block returns the block received over gossip for slot, if any
+func (r *GossipRecorder) Block(slot consensustypes.Slot) (*p2pclient.GossipBlock, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.blocks[slot]
+	return b, ok
+}
+
+// AssertBlobsGossiped checks that the block of slot and its expected number of blobs were received over gossip
+func (r *GossipRecorder) AssertBlobsGossiped(slot consensustypes.Slot, blobs int) {
+	b, ok := r.Block(slot)
+	if !ok {
+		Fatalf("no block received over gossip for slot %d", slot)
+	}
+	if b.Blobs != blobs {
+		Fatalf("block of slot %d was gossiped with %d blobs, expected %d", slot, b.Blobs, blobs)
+	}
+}
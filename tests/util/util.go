@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/pkg/errors"
@@ -37,7 +36,7 @@ func WaitForSlot(ctx context.Context, client *beacon.Client, slot consensustypes
 
 func WaitForNextSlots(ctx context.Context, client *beacon.Client, slots consensustypes.Slot) {
 	if err := WaitForSlot(ctx, client, GetHeadSlot(ctx, client).AddSlot(slots)); err != nil {
-		log.Fatalf("error waiting for next slot: %v", err)
+		Fatalf("error waiting for next slot: %v", err)
 	}
 }
 
@@ -89,7 +88,7 @@ func GetBlock(ctx context.Context, client *beacon.Client, blockId beacon.StateOr
 
 	marshaled, err := client.GetBlock(ctx, blockId)
 	if err != nil {
-		log.Fatalf("unable to get beacon chain block: %v", err)
+		Fatalf("unable to get beacon chain block: %v", err)
 	}
 	err = m.UnmarshalSSZ(marshaled)
 	if err != nil {
@@ -115,7 +114,7 @@ func GetBlock(ctx context.Context, client *beacon.Client, blockId beacon.StateOr
 func GetHeadSlot(ctx context.Context, client *beacon.Client) consensustypes.Slot {
 	block, err := GetBlock(ctx, client, "head")
 	if err != nil {
-		log.Fatalf("GetBlock error: %v", err)
+		Fatalf("GetBlock error: %v", err)
 	}
 	return block.Data.Message.Slot
 }
@@ -127,12 +126,12 @@ func FindBlobSlot(ctx context.Context, client *beacon.Client, startSlot consensu
 	endSlot := GetHeadSlot(ctx, client)
 	for {
 		if slot == endSlot {
-			log.Fatalf("Unable to find beacon block containing blobs")
+			Fatalf("Unable to find beacon block containing blobs")
 		}
 
 		block, err := GetBlock(ctx, client, beacon.IdFromSlot(slot))
 		if err != nil {
-			log.Fatalf("beaconchainclient.GetBlock: %v", err)
+			Fatalf("beaconchainclient.GetBlock: %v", err)
 		}
 
 		if len(block.Data.Message.Body.BlobKzgCommitments) != 0 {
@@ -145,12 +144,12 @@ func FindBlobSlot(ctx context.Context, client *beacon.Client, startSlot consensu
 
 func AssertBlobsEquals(a, b types.Blobs) {
 	if len(a) != len(b) {
-		log.Fatalf("data length mismatch (%d != %d)", len(a), len(b))
+		Fatalf("data length mismatch (%d != %d)", len(a), len(b))
 	}
 	for i, _ := range a {
 		for j := 0; j < params.FieldElementsPerBlob; j++ {
 			if !bytes.Equal(a[i][j][:], b[i][j][:]) {
-				log.Fatal("blobs data mismatch")
+				Fatal("blobs data mismatch")
 			}
 		}
 	}
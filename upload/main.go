@@ -2,109 +2,351 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"math/big"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/Inphi/eip4844-interop/shared/accounts"
+	"github.com/Inphi/eip4844-interop/shared/blobtx"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/holiman/uint256"
-	"github.com/protolambda/ztyp/view"
 )
 
 func main() {
-	prv := "45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8"
+	if len(os.Args) > 1 && os.Args[1] == "send-raw" {
+		sendRaw(os.Args[2:])
+		return
+	}
 
 	before := flag.Uint64("before", 0, "Block to wait for before submitting transaction")
 	after := flag.Uint64("after", 0, "Block to wait for after submitting transaction")
 	addr := flag.String("addr", "http://localhost:8545", "JSON-RPC endpoint")
+	dense := flag.Bool("dense", false, "Pack blobs densely, using all 254 bits of each field element")
+	blobsPerTx := flag.Int("blobs-per-tx", shared.MaxBlobsPerTx, "Maximum number of blobs in each transaction")
+	manifestPath := flag.String("manifest", "", "Write a manifest describing the uploaded transactions to this file")
+	maxFeePerDataGas := flag.Uint64("max-fee-per-data-gas", 3000000000, "Max fee per data gas in wei. Overrides the estimate when used with --auto-fee")
+	autoFee := flag.Bool("auto-fee", false, "Estimate execution and data gas fees from the latest block instead of using fixed fees")
+	account := flag.String("account", "", "Index or address of a funded account derived from the devnet mnemonic. Defaults to the genesis premine account")
+	keystorePath := flag.String("keystore", "", "Geth keystore file of the sending account")
+	passwordFile := flag.String("password-file", "", "File containing the keystore passphrase")
+	feeMultiplier := flag.Float64("fee-multiplier", shared.DefaultFeeMultiplier, "Safety multiplier applied to the estimated data gas price")
+	output := flag.String("output", "text", "Output format, text or json. With json, waits for the transactions to be included and prints a report to stdout")
+	beaconAPI := flag.String("beacon-api", "http://"+shared.BeaconAPI, "Beacon node REST API, used to find the slot of the inclusion block with --output json")
+	dryRun := flag.Bool("dry-run", false, "Build and sign the transactions without sending them, writing their network encoding to --raw-out")
+	rawOut := flag.String("raw-out", "-", "File the hex-encoded transactions are written to with --dry-run, one per line. - for stdout")
+	chainIDFlag := flag.Uint64("chain-id", 0, "Chain ID. Retrieved from the node if not set")
+	nonceFlag := flag.Uint64("nonce", 0, "Nonce of the first transaction with --dry-run. Retrieved from the node if not set")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <file|->\n       %s send-raw [flags] <file|->\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	if *dryRun && *output == "json" && (*rawOut == "" || *rawOut == "-") {
+		log.Fatalf("--output json with --dry-run needs --raw-out to be a file")
+	}
+	if *output != "text" && *output != "json" {
+		log.Fatalf("Unknown output format %q", *output)
+	}
+
 	file := flag.Arg(0)
 	if file == "" {
 		log.Fatalf("File parameter missing")
 	}
-	data, err := os.ReadFile(file)
+	var in io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalf("Error opening file: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	counter := &countingReader{r: in}
+	readBlobs := shared.ReadBlobs
+	if *dense {
+		readBlobs = shared.ReadBlobsDense
+	}
+	blobs, err := readBlobs(counter)
 	if err != nil {
 		log.Fatalf("Error reading file: %v", err)
 	}
 
+	if *blobsPerTx < 1 || *blobsPerTx > shared.MaxBlobsPerTx {
+		log.Fatalf("blobs-per-tx must be between 1 and %d", shared.MaxBlobsPerTx)
+	}
+	if len(blobs) > *blobsPerTx && *manifestPath == "" {
+		log.Fatalf("File needs %d blobs, which doesn't fit in a single transaction. Use --manifest to upload it in chunks", len(blobs))
+	}
+
 	ctx := context.Background()
+	// the node is only contacted for what isn't set on the command line, so --dry-run works offline
 	client, err := ethclient.DialContext(ctx, *addr)
 	if err != nil {
 		log.Fatalf("Failed to connect to the Ethereum client: %v", err)
 	}
 
-	chainId, err := client.ChainID(ctx)
-	if err != nil {
-		log.Fatalf("failed to retrieve chain id: %v", err)
+	chainId := new(big.Int).SetUint64(*chainIDFlag)
+	if !isFlagSet("chain-id") {
+		chainId, err = client.ChainID(ctx)
+		if err != nil {
+			log.Fatalf("failed to retrieve chain id: %v", err)
+		}
 	}
-	signer := types.NewDankSigner(chainId)
 
-	key, err := crypto.HexToECDSA(prv)
+	sender, err := loadAccount(*account, *keystorePath, *passwordFile)
 	if err != nil {
-		log.Fatalf("Failed to load private key: %v", err)
+		log.Fatalf("Failed to load account: %v", err)
 	}
+	log.Printf("Sender: %v", sender.Address)
 
 	if *before > 0 {
 		waitForBlock(ctx, client, *before)
 	}
 
-	bn, err := client.BlockNumber(ctx)
-	if err != nil {
-		log.Fatalf("Error getting block number: %v", err)
+	fees := &shared.BlobTxFees{
+		GasTipCap:        big.NewInt(5000000000),
+		GasFeeCap:        big.NewInt(5000000000),
+		MaxFeePerDataGas: new(big.Int).SetUint64(*maxFeePerDataGas),
+	}
+	if *autoFee {
+		fees, err = shared.EstimateBlobTxFees(ctx, client, *feeMultiplier)
+		if err != nil {
+			log.Fatalf("Error estimating fees: %v", err)
+		}
+		if isFlagSet("max-fee-per-data-gas") {
+			fees.MaxFeePerDataGas = new(big.Int).SetUint64(*maxFeePerDataGas)
+		}
 	}
+	log.Printf("Fees: gasTipCap=%v gasFeeCap=%v maxFeePerDataGas=%v", fees.GasTipCap, fees.GasFeeCap, fees.MaxFeePerDataGas)
+
+	builder := blobtx.NewBuilder(client, blobtx.WithChainID(chainId), blobtx.WithFees(fees))
 	// note: etherumjs doesn't support PendingNonceAt
-	nonce, err := client.NonceAt(ctx, crypto.PubkeyToAddress(key.PublicKey), new(big.Int).SetUint64(bn))
-	if err != nil {
-		log.Fatalf("Error getting nonce: %v", err)
-	}
-	log.Printf("Nonce: %d", nonce)
-
-	blobs := shared.EncodeBlobs(data)
-	commitments, versionedHashes, aggregatedProof, err := blobs.ComputeCommitmentsAndAggregatedProof()
-
-	to := common.HexToAddress("ffb38a7a99e3e2335be83fc74b7faa19d5531243")
-	txData := types.SignedBlobTx{
-		Message: types.BlobTxMessage{
-			ChainID:             view.Uint256View(*uint256.NewInt(chainId.Uint64())),
-			Nonce:               view.Uint64View(nonce),
-			Gas:                 210000,
-			GasFeeCap:           view.Uint256View(*uint256.NewInt(5000000000)),
-			GasTipCap:           view.Uint256View(*uint256.NewInt(5000000000)),
-			MaxFeePerDataGas:    view.Uint256View(*uint256.NewInt(3000000000)), // needs to be at least the min fee
-			Value:               view.Uint256View(*uint256.NewInt(12345678)),
-			To:                  types.AddressOptionalSSZ{Address: (*types.AddressSSZ)(&to)},
-			BlobVersionedHashes: versionedHashes,
-		},
-	}
-
-	wrapData := types.BlobTxWrapData{
-		BlobKzgs:           commitments,
-		Blobs:              blobs,
-		KzgAggregatedProof: aggregatedProof,
-	}
-	tx := types.NewTx(&txData, types.WithTxWrapData(&wrapData))
-	tx, err = types.SignTx(tx, signer, key)
-	if err != nil {
-		log.Fatalf("Error signing tx: %v", err)
+	txSender := blobtx.NewSender(builder, blobtx.WithLatestNonce())
+	manifest := shared.Manifest{Length: counter.n}
+	var sent []*types.Transaction
+	if *dryRun {
+		nonce := *nonceFlag
+		if !isFlagSet("nonce") {
+			nonce, err = client.NonceAt(ctx, sender.Address, nil)
+			if err != nil {
+				log.Fatalf("Error getting nonce: %v", err)
+			}
+		}
+		sent, err = buildTransactions(ctx, builder, sender, blobs, *blobsPerTx, nonce)
+		if err != nil {
+			log.Fatalf("Error building tx: %v", err)
+		}
+		if err := writeRawTransactions(*rawOut, sent); err != nil {
+			log.Fatalf("Error writing transactions: %v", err)
+		}
+	} else {
+		for i := 0; i < len(blobs); i += *blobsPerTx {
+			end := i + *blobsPerTx
+			if end > len(blobs) {
+				end = len(blobs)
+			}
+			tx, err := txSender.Send(ctx, sender.Key, blobtx.WithBlobs(blobs[i:end]))
+			if err != nil {
+				log.Fatalf("Error sending tx: %v", err)
+			}
+			log.Printf("Transaction submitted. hash=%v nonce=%d blobs=%d", tx.Hash(), tx.Nonce(), end-i)
+			sent = append(sent, tx)
+		}
 	}
 
-	err = client.SendTransaction(ctx, tx)
-	if err != nil {
-		log.Fatalf("Error sending tx: %v", err)
+	var report *uploadReport
+	if *output == "json" {
+		report = &uploadReport{Sender: sender.Address, Length: counter.n, Manifest: *manifestPath}
+	}
+	for _, tx := range sent {
+		hash := tx.Hash()
+		if report != nil && *dryRun {
+			report.Transactions = append(report.Transactions, newUploadedTx(ctx, client, *beaconAPI, tx, nil))
+		} else if report != nil {
+			receipt, err := txSender.Wait(ctx, tx)
+			if err != nil {
+				log.Fatalf("Error waiting for tx %v: %v", tx.Hash(), err)
+			}
+			// the transaction may have been replaced while waiting
+			hash = receipt.TxHash
+			report.Transactions = append(report.Transactions, newUploadedTx(ctx, client, *beaconAPI, tx, receipt))
+		}
+
+		versionedHashes := tx.DataHashes()
+		manifest.Blobs = append(manifest.Blobs, versionedHashes...)
+		manifest.Transactions = append(manifest.Transactions, shared.ManifestTransaction{
+			Hash:            hash,
+			Nonce:           tx.Nonce(),
+			VersionedHashes: versionedHashes,
+		})
 	}
 
-	log.Printf("Transaction submitted. hash=%v", tx.Hash())
+	if *manifestPath != "" {
+		if err := shared.WriteManifest(*manifestPath, &manifest); err != nil {
+			log.Fatalf("Error writing manifest: %v", err)
+		}
+		log.Printf("Manifest written to %s. transactions=%d blobs=%d", *manifestPath, len(manifest.Transactions), len(manifest.Blobs))
+	}
 
 	if *after > 0 {
 		waitForBlock(ctx, client, *after)
 	}
+
+	if report != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("Error writing report: %v", err)
+		}
+	}
+}
+
+type uploadReport struct {
+	Sender       common.Address `json:"sender"`
+	Length       uint64         `json:"length"`
+	Manifest     string         `json:"manifest,omitempty"`
+	Transactions []uploadedTx   `json:"transactions"`
+}
+
+type uploadedTx struct {
+	Hash            common.Hash           `json:"hash"`
+	Nonce           uint64                `json:"nonce"`
+	VersionedHashes []common.Hash         `json:"versionedHashes"`
+	Commitments     []types.KZGCommitment `json:"commitments"`
+	AggregatedProof types.KZGProof        `json:"aggregatedProof"`
+	// BlockNumber, BlockHash and Slot are omitted for transactions that weren't sent,
+	// and Slot is omitted if the beacon node could not be queried
+	BlockNumber *uint64      `json:"blockNumber,omitempty"`
+	BlockHash   *common.Hash `json:"blockHash,omitempty"`
+	Slot        *uint64      `json:"slot,omitempty"`
+}
+
+func newUploadedTx(ctx context.Context, client *ethclient.Client, beaconAPI string, tx *types.Transaction, receipt *types.Receipt) uploadedTx {
+	versionedHashes, commitments, _, proof := tx.BlobWrapData()
+	utx := uploadedTx{
+		Hash:            tx.Hash(),
+		Nonce:           tx.Nonce(),
+		VersionedHashes: versionedHashes,
+		Commitments:     commitments,
+		AggregatedProof: proof,
+	}
+	if receipt == nil {
+		return utx
+	}
+	bn := receipt.BlockNumber.Uint64()
+	utx.Hash = receipt.TxHash
+	utx.BlockNumber = &bn
+	utx.BlockHash = &receipt.BlockHash
+
+	header, err := client.HeaderByHash(ctx, receipt.BlockHash)
+	if err != nil {
+		log.Printf("Unable to get block %v: %v", receipt.BlockHash, err)
+		return utx
+	}
+	slot, err := shared.GetSlotAtTime(beaconAPI, header.Time)
+	if err != nil {
+		log.Printf("Unable to find the slot of block %v: %v", receipt.BlockHash, err)
+		return utx
+	}
+	utx.Slot = &slot
+	return utx
+}
+
+// buildTransactions signs transactions carrying blobs, blobsPerTx at a time, starting at the given nonce
+func buildTransactions(ctx context.Context, builder *blobtx.Builder, sender *accounts.Account, blobs types.Blobs, blobsPerTx int, nonce uint64) ([]*types.Transaction, error) {
+	var txs []*types.Transaction
+	for i := 0; i < len(blobs); i += blobsPerTx {
+		end := i + blobsPerTx
+		if end > len(blobs) {
+			end = len(blobs)
+		}
+		tx, err := builder.Build(ctx, blobtx.WithKey(sender.Key), blobtx.WithNonce(nonce), blobtx.WithBlobs(blobs[i:end]))
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Transaction signed. hash=%v nonce=%d blobs=%d", tx.Hash(), tx.Nonce(), end-i)
+		txs = append(txs, tx)
+		nonce++
+	}
+	return txs, nil
+}
+
+// writeRawTransactions writes the network encoding of txs, including their blobs, commitments and proof,
+// as one hex string per line. This is the format expected by eth_sendRawTransaction and send-raw.
+func writeRawTransactions(path string, txs []*types.Transaction) error {
+	var w io.Writer = os.Stdout
+	if path != "" && path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	for _, tx := range txs {
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, hexutil.Encode(raw)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadAccount(selector, keystorePath, passwordFile string) (*accounts.Account, error) {
+	if keystorePath != "" {
+		var passphrase string
+		if passwordFile != "" {
+			data, err := os.ReadFile(passwordFile)
+			if err != nil {
+				return nil, err
+			}
+			passphrase = strings.TrimRight(string(data), "\r\n")
+		}
+		return accounts.LoadKeystore(keystorePath, passphrase)
+	}
+	if selector == "" {
+		return accounts.Default()
+	}
+	funded, err := accounts.Funded()
+	if err != nil {
+		return nil, err
+	}
+	return accounts.Select(funded, selector)
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n uint64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += uint64(n)
+	return n, err
 }
 
 func waitForBlock(ctx context.Context, client *ethclient.Client, block uint64) {
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// sendRaw submits transactions written by --dry-run through eth_sendRawTransaction, printing their hashes to stdout
+func sendRaw(args []string) {
+	fs := flag.NewFlagSet("send-raw", flag.ExitOnError)
+	addr := fs.String("addr", "http://localhost:8545", "JSON-RPC endpoint")
+	_ = fs.Parse(args)
+
+	file := fs.Arg(0)
+	if file == "" {
+		log.Fatalf("File parameter missing")
+	}
+	var in io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalf("Error opening file: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	ctx := context.Background()
+	client, err := rpc.DialContext(ctx, *addr)
+	if err != nil {
+		log.Fatalf("Failed to connect to the Ethereum client: %v", err)
+	}
+	defer client.Close()
+
+	scanner := bufio.NewScanner(in)
+	// a hex-encoded transaction with MaxBlobsPerTx blobs is over 1 MiB
+	scanner.Buffer(nil, 8<<20)
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		if _, err := hexutil.Decode(raw); err != nil {
+			log.Fatalf("Invalid transaction on line %d: %v", line, err)
+		}
+		var hash common.Hash
+		if err := client.CallContext(ctx, &hash, "eth_sendRawTransaction", raw); err != nil {
+			log.Fatalf("Error sending transaction on line %d: %v", line, err)
+		}
+		log.Printf("Transaction submitted. hash=%v", hash)
+		fmt.Println(hash.Hex())
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Error reading file: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/Inphi/eip4844-interop/shared"
+	"github.com/Inphi/eip4844-interop/shared/p2pclient"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prysmaticlabs/prysm/v3/api/client/beacon"
+)
+
+const discoveryTimeout = 30 * time.Second
+
+type record struct {
+	Slot          uint64      `json:"slot"`
+	ProposerIndex uint64      `json:"proposerIndex"`
+	BlockRoot     common.Hash `json:"blockRoot"`
+	Blobs         int         `json:"blobs"`
+	From          string      `json:"from"`
+	ReceivedAt    time.Time   `json:"receivedAt"`
+	// LatencyMs is the time between the start of the slot and the arrival of the message
+	LatencyMs int64 `json:"latencyMs"`
+}
+
+func main() {
+	addrs := flag.String("addr", "", "Comma-separated P2P addresses to connect to, including the /p2p/<peer id> suffix")
+	enrs := flag.String("enr", "", "Comma-separated ENRs of beacon nodes to connect to")
+	bootENR := flag.String("boot-enr", shared.BootENRFilepath(), "Bootnode ENR list used to discover beacon nodes through discv5 when neither --addr nor --enr is set")
+	peers := flag.Int("peers", 2, "Number of beacon nodes to discover")
+	beaconAPI := flag.String("beacon-api", "http://"+shared.BeaconAPI, "Beacon node REST API used to read the chain config")
+	output := flag.String("output", "text", "Output format, text or json (one object per line)")
+	out := flag.String("out", "", "Also record every message to this file, one JSON object per line")
+	duration := flag.Duration("duration", 0, "Stop after this long. Runs until interrupted if not set")
+	count := flag.Int("count", 0, "Stop after this many messages. Unlimited if not set")
+	flag.Parse()
+
+	if *output != "text" && *output != "json" {
+		log.Fatalf("Unknown output format %q", *output)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *duration != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	beaconClient, err := beacon.NewClient(*beaconAPI)
+	if err != nil {
+		log.Fatalf("Invalid beacon API: %v", err)
+	}
+	chain, err := p2pclient.FetchChainConfig(ctx, beaconClient)
+	if err != nil {
+		log.Fatalf("Unable to read the chain config from %s: %v", *beaconAPI, err)
+	}
+	client, err := p2pclient.New(chain)
+	if err != nil {
+		log.Fatalf("Failed to create p2p client: %v", err)
+	}
+	defer client.Close()
+	// start gossipsub before connecting, so that peers learn our subscriptions in the handshake
+	if _, err := client.Gossip(); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if err := connect(ctx, client, *addrs, *enrs, *bootENR, *peers); err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+
+	var recorder io.Writer
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Error creating file: %v", err)
+		}
+		defer f.Close()
+		recorder = f
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	received := 0
+	err = client.WatchBlocksAndBlobs(watchCtx, func(b *p2pclient.GossipBlock) {
+		r := record{
+			Slot:          uint64(b.Slot),
+			ProposerIndex: uint64(b.ProposerIndex),
+			BlockRoot:     b.BlockRoot,
+			Blobs:         b.Blobs,
+			From:          b.From.String(),
+			ReceivedAt:    b.ReceivedAt,
+			LatencyMs:     b.Latency.Milliseconds(),
+		}
+		if *output == "json" {
+			writeRecord(os.Stdout, r)
+		} else {
+			fmt.Printf("slot=%d proposer=%d root=%v blobs=%d latency=%v from=%s\n", r.Slot, r.ProposerIndex, r.BlockRoot, r.Blobs, b.Latency, r.From)
+		}
+		if recorder != nil {
+			writeRecord(recorder, r)
+		}
+		received++
+		if *count != 0 && received >= *count {
+			cancel()
+		}
+	}, func(err error) {
+		log.Printf("Invalid gossip message: %v", err)
+	})
+	if err != nil {
+		log.Fatalf("Failed to watch gossip: %v", err)
+	}
+	log.Printf("Received %d messages", received)
+}
+
+func writeRecord(w io.Writer, r record) {
+	if err := json.NewEncoder(w).Encode(r); err != nil {
+		log.Fatalf("Error writing record: %v", err)
+	}
+}
+
+func connect(ctx context.Context, client *p2pclient.Client, addrs, enrs, bootENR string, peers int) error {
+	if addrs == "" && enrs == "" {
+		bootnodes, err := p2pclient.ReadENRFile(bootENR)
+		if err != nil {
+			return fmt.Errorf("%w: no --addr or --enr given, and the bootnodes can't be read", err)
+		}
+		discoverCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+		defer cancel()
+		pids, err := client.ConnectDiscovered(discoverCtx, bootnodes, peers)
+		if err != nil {
+			return err
+		}
+		log.Printf("Connected to %d discovered peers", len(pids))
+		return nil
+	}
+	for _, addr := range split(addrs) {
+		if _, err := client.Connect(ctx, addr); err != nil {
+			return err
+		}
+	}
+	for _, s := range split(enrs) {
+		node, err := p2pclient.ParseENR(s)
+		if err != nil {
+			return err
+		}
+		if _, err := client.ConnectNode(ctx, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func split(s string) []string {
+	var parts []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}